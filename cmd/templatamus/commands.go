@@ -0,0 +1,442 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"templatamus/internal/config"
+	"templatamus/internal/model"
+	"templatamus/internal/paths"
+	"templatamus/internal/provider"
+	"templatamus/internal/sync"
+)
+
+const usage = `Usage: templatamus [-v] [command] [flags]
+
+Commands:
+  new      Create a new project from a template repository
+  sync     Apply upstream commits to an existing project
+  upgrade  Move the project to another template tag in a single merge
+  resolve  Resolve the conflicts a sync stopped at, file by file
+  status   Show the project's source and pending upstream commits
+  help     Show this help
+
+Running templatamus without a command detects whether the current
+directory is a templatamus project and either syncs it or creates a
+new one. Any value not given as a flag is prompted for.
+Run 'templatamus <command> -h' to see the flags of a command.
+
+Flags:
+  -v, -verbose  Report details such as where the API token was found
+`
+
+// verbose is set by the -v flag given before the command
+var verbose bool
+
+// runCommand parses the global flags and dispatches to the subcommand named in args
+func runCommand(args []string) error {
+	fs := flag.NewFlagSet("templatamus", flag.ContinueOnError)
+	fs.Usage = func() { fmt.Print(usage) }
+	fs.BoolVar(&verbose, "v", false, "report details such as where the API token was found")
+	fs.BoolVar(&verbose, "verbose", false, "report details such as where the API token was found")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	args = fs.Args()
+
+	if len(args) == 0 {
+		return runDefault()
+	}
+
+	switch args[0] {
+	case "new":
+		return runNew(args[1:])
+	case "sync":
+		return runSync(args[1:])
+	case "upgrade":
+		return runUpgrade(args[1:])
+	case "resolve":
+		return runResolve(args[1:])
+	case "status":
+		return runStatus(args[1:])
+	case "help":
+		fmt.Print(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q, run 'templatamus help' for usage", args[0])
+	}
+}
+
+// runDefault keeps the original interactive behaviour of detecting the project
+func runDefault() error {
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+
+	// Detect project
+	dir, isExisting, err := sync.DetectProject()
+	if err != nil {
+		return err
+	}
+
+	if isExisting {
+		unlock, err := lockProject(dir)
+		if err != nil {
+			return err
+		}
+		defer unlock()
+
+		src, err := projectSource(cfg, dir)
+		if err != nil {
+			return err
+		}
+		return sync.SyncProject(dir, src, sync.Options{})
+	}
+	return createNewProject(cfg, newOptions{Dest: dir})
+}
+
+// runNew parses the flags of the new command and creates a project
+func runNew(args []string) error {
+	fs := flag.NewFlagSet("new", flag.ContinueOnError)
+	var opts newOptions
+	fs.StringVar(&opts.Repo, "repo", "", "template repository in the form owner/repo")
+	fs.StringVar(&opts.Branch, "branch", "", "create the project from this branch")
+	fs.StringVar(&opts.Tag, "tag", "", "create the project from this tag")
+	fs.StringVar(&opts.Commit, "commit", "", "create the project from this commit and pin it there")
+	fs.BoolVar(&opts.Head, "head", false, "create the project from the head of the default branch")
+	fs.StringVar(&opts.Dest, "dest", "", "directory to create the project in")
+	opts.Vars = make(map[string]string)
+	fs.Var(varsFlag(opts.Vars), "var", "template variable as NAME=VALUE (can be repeated)")
+	gitInit := fs.Bool("git-init", false, "init a git repo with an initial commit (use -git-init=false to skip)")
+	yes := fs.Bool("yes", false, "run the template's post-create steps without asking")
+	noPostCreate := fs.Bool("no-post-create", false, "skip the template's post-create steps")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	refFlags := 0
+	for _, set := range []bool{opts.Head, opts.Branch != "", opts.Tag != "", opts.Commit != ""} {
+		if set {
+			refFlags++
+		}
+	}
+	if refFlags > 1 {
+		return fmt.Errorf("only one of -head, -branch, -tag and -commit can be given")
+	}
+
+	// Only use the git-init flag when it was given explicitly, otherwise ask
+	if flagPassed(fs, "git-init") {
+		opts.GitInit = gitInit
+	}
+
+	// The post-create steps are asked about unless one of their flags is given
+	switch {
+	case *yes && *noPostCreate:
+		return fmt.Errorf("only one of -yes and -no-post-create can be given")
+	case *yes:
+		opts.PostCreate = yes
+	case *noPostCreate:
+		run := false
+		opts.PostCreate = &run
+	}
+
+	if opts.Dest != "" {
+		dest, err := resolvePath(opts.Dest)
+		if err != nil {
+			return err
+		}
+		opts.Dest = dest
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	return createNewProject(cfg, opts)
+}
+
+// runSync parses the flags of the sync command and syncs the project
+func runSync(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ContinueOnError)
+	var opts sync.Options
+	dirFlag := fs.String("dir", ".", "project directory")
+	fs.BoolVar(&opts.All, "all", false, "apply all pending commits without prompting")
+	fs.BoolVar(&opts.DryRun, "dry-run", false, "report how the selected commits would merge without changing anything")
+	fs.BoolVar(&opts.Continue, "continue", false, "resume the sync in progress once its conflicts are resolved")
+	fs.BoolVar(&opts.Skip, "skip", false, "drop the commit the sync in progress stopped at and continue with the next one")
+	fs.BoolVar(&opts.IncludeSkipped, "include-skipped", false, "offer the commits that were skipped before again")
+	abort := fs.Bool("abort", false, "undo the sync in progress and restore the project")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	dir, err := projectDir(*dirFlag)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProject(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if *abort {
+		return sync.AbortSync(dir)
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	src, err := projectSource(cfg, dir)
+	if err != nil {
+		return err
+	}
+	return sync.SyncProject(dir, src, opts)
+}
+
+// runUpgrade parses the flags of the upgrade command and upgrades the project
+func runUpgrade(args []string) error {
+	fs := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	to := fs.String("to", "", "tag to upgrade the project to")
+	dirFlag := fs.String("dir", ".", "project directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	dir, err := projectDir(*dirFlag)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProject(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	src, err := projectSource(cfg, dir)
+	if err != nil {
+		return err
+	}
+	return sync.Upgrade(dir, src, *to)
+}
+
+// runResolve parses the flags of the resolve command and resolves the sync's conflicts
+func runResolve(args []string) error {
+	fs := flag.NewFlagSet("resolve", flag.ContinueOnError)
+	dirFlag := fs.String("dir", ".", "project directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	dir, err := projectDir(*dirFlag)
+	if err != nil {
+		return err
+	}
+	unlock, err := lockProject(dir)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	return sync.ResolveConflicts(dir)
+}
+
+// runStatus parses the flags of the status command and prints the project status
+func runStatus(args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	dirFlag := fs.String("dir", ".", "project directory")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+
+	dir, err := projectDir(*dirFlag)
+	if err != nil {
+		return err
+	}
+
+	cfg, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	src, err := projectSource(cfg, dir)
+	if err != nil {
+		return err
+	}
+	return sync.PrintStatus(dir, src)
+}
+
+// loadConfig loads the user configuration
+func loadConfig() (*model.UserConfig, error) {
+	cfg, err := config.LoadUserConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+	return cfg, nil
+}
+
+// projectSource creates the provider for the source the project in dir was created from
+func projectSource(cfg *model.UserConfig, dir string) (provider.SourceProvider, error) {
+	metadata, err := config.LoadProjectMetadata(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load project metadata: %w", err)
+	}
+	src, _, err := newSource(cfg, metadata.SourceRepo, model.ServerConfig{
+		APIURL:      metadata.SourceAPIURL,
+		DownloadURL: metadata.SourceDownloadURL,
+	})
+	return src, err
+}
+
+// newSource creates the provider for a repo and returns it with the URLs it
+// uses, to be recorded in a new project. The URLs of recorded, such as those of
+// the server a project was created from, take precedence over the config; a
+// configured download URL is only kept if recorded has none and the config
+// still points at the same API. The token is the one config.ResolveToken finds
+// for the server.
+func newSource(cfg *model.UserConfig, repo string, recorded model.ServerConfig) (provider.SourceProvider, model.ServerConfig, error) {
+	// The global URLs are those of a GitHub Enterprise Server, so other sources
+	// only use the URLs of their own entry
+	opts := provider.Options{Server: cfg.RepoServer(repo)}
+	if scheme, _ := provider.ParseRepo(repo); scheme == "github" {
+		opts.Server = cfg.ServerFor(repo)
+	}
+	src, err := provider.New(repo, opts)
+	if err != nil {
+		return nil, model.ServerConfig{}, err
+	}
+	apiURL := src.APIURL()
+	if recorded.APIURL != "" && recorded.APIURL != apiURL {
+		opts.Server = model.ServerConfig{APIURL: recorded.APIURL}
+		apiURL = recorded.APIURL
+	}
+	if recorded.DownloadURL != "" {
+		opts.Server.DownloadURL = recorded.DownloadURL
+	}
+	// Sources without an API, such as plain git remotes, don't take a token
+	if apiURL == "" {
+		return src, model.ServerConfig{}, nil
+	}
+
+	// Archives come from the API unless a download URL is set
+	server := model.ServerConfig{APIURL: apiURL, DownloadURL: strings.TrimSuffix(opts.Server.DownloadURL, "/")}
+	if server.DownloadURL == "" {
+		server.DownloadURL = apiURL
+	}
+
+	// The token depends on the host, which is only known once the provider
+	// has filled in its default API URL
+	token := config.ResolveToken(cfg, apiURL)
+	if verbose {
+		if token.Value == "" {
+			fmt.Printf("No token found for %s, sending requests without one.\n", apiURL)
+		} else {
+			fmt.Printf("Using the token for %s from %s.\n", apiURL, token.Source)
+		}
+	}
+	opts.Token = token.Value
+	src, err = provider.New(repo, opts)
+	if err != nil {
+		return nil, model.ServerConfig{}, err
+	}
+	return src, server, nil
+}
+
+// projectDir resolves the given path and checks that it is a templatamus project
+func projectDir(path string) (string, error) {
+	dir, err := resolvePath(path)
+	if err != nil {
+		return "", err
+	}
+	if !config.HasProjectMetadata(dir) {
+		return "", fmt.Errorf("%s is not a templatamus project", dir)
+	}
+	return dir, nil
+}
+
+// lockProject takes the lock on the project at dir for the rest of a command,
+// so another templatamus run can't change it at the same time. The returned
+// function releases the lock.
+func lockProject(dir string) (func(), error) {
+	lock, err := config.LockProject(dir)
+	if err != nil {
+		return nil, err
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			fmt.Printf("Warning: %v\n", err)
+		}
+	}, nil
+}
+
+// resolvePath expands ~ and returns the absolute form of path
+func resolvePath(path string) (string, error) {
+	expanded, err := paths.Expand(path)
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(filepath.Clean(expanded))
+	if err != nil {
+		return "", fmt.Errorf("invalid path: %w", err)
+	}
+	return abs, nil
+}
+
+// varsFlag collects repeated NAME=VALUE flags into a map
+type varsFlag map[string]string
+
+func (v varsFlag) String() string {
+	pairs := make([]string, 0, len(v))
+	for name, value := range v {
+		pairs = append(pairs, name+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (v varsFlag) Set(s string) error {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("expected NAME=VALUE, got %q", s)
+	}
+	v[name] = value
+	return nil
+}
+
+// flagPassed reports whether the named flag was set on the command line
+func flagPassed(fs *flag.FlagSet, name string) bool {
+	passed := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			passed = true
+		}
+	})
+	return passed
+}
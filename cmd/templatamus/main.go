@@ -1,17 +1,22 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"log"
-	"strings"
+	"os"
+	"path/filepath"
 	"time"
 
 	"templatamus/internal/cli"
-	"templatamus/internal/config"
 	"templatamus/internal/git"
-	"templatamus/internal/github"
 	"templatamus/internal/model"
+	"templatamus/internal/provider"
 	"templatamus/internal/sync"
+
+	// Source providers register themselves with the provider package
+	_ "templatamus/internal/github"
+	_ "templatamus/internal/gitlab"
 )
 
 // These variables are set during compilation
@@ -29,171 +34,192 @@ func Main() {
 	// Display version information
 	fmt.Printf("Templatamus v%s (built %s)\n\n", Version, BuildDate)
 
-	// Load user configuration
-	cfg, err := config.LoadUserConfig()
-	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
-	}
-
-	// Create GitHub client
-	client := github.NewClient(cfg.Token)
-
-	// Detect project
-	dir, isExisting, err := sync.DetectProject()
-	if err != nil {
-		log.Fatalf("Error: %v", err)
-	}
-
-	if !isExisting {
-		// Create new project
-		if err := createNewProject(dir, cfg, client); err != nil {
-			log.Fatalf("Error: %v", err)
-		}
-	} else {
-		// Sync existing project
-		if err := sync.SyncProject(dir, client); err != nil {
+	if err := runCommand(os.Args[1:]); err != nil {
+		switch {
+		case errors.Is(err, provider.ErrUnauthorized):
+			log.Fatalf("Error: %v\nCheck that your token is valid and has access to the repository, run with -v to see where it was found.", err)
+		case errors.Is(err, provider.ErrRateLimited):
+			log.Fatalf("Error: %v\nThe API rate limit was reached, please try again later.", err)
+		default:
 			log.Fatalf("Error: %v", err)
 		}
 	}
 }
 
-// getCommitSHAForTag gets the commit SHA for a tag
-func getCommitSHAForTag(client *github.Client, owner, repo, tag string) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/git/refs/tags/%s", owner, repo, tag)
-	
-	var tagRef struct {
-		Object struct {
-			SHA string `json:"sha"`
-			Type string `json:"type"`
-			URL string `json:"url"`
-		} `json:"object"`
-	}
-	
-	if err := client.GetJSON(url, &tagRef); err != nil {
-		return "", fmt.Errorf("failed to get tag reference: %w", err)
-	}
-	
-	// If it's a tag object, we need to get the commit it points to
-	if tagRef.Object.Type == "tag" {
-		var tagObj struct {
-			Object struct {
-				SHA string `json:"sha"`
-			} `json:"object"`
-		}
-		
-		if err := client.GetJSON(tagRef.Object.URL, &tagObj); err != nil {
-			return "", fmt.Errorf("failed to get tag object: %w", err)
-		}
-		
-		return tagObj.Object.SHA, nil
-	}
-	
-	// It's a direct reference to a commit
-	return tagRef.Object.SHA, nil
+// newOptions holds the values for creating a new project; anything left empty is prompted for
+type newOptions struct {
+	Repo    string
+	Branch  string
+	Tag     string
+	Commit  string
+	Head    bool
+	Dest    string
+	GitInit *bool
+	// PostCreate says whether to run the template's post-create steps, nil to ask
+	PostCreate *bool
+	Vars    map[string]string
 }
 
 // createNewProject handles creating a new project
-func createNewProject(targetDir string, cfg *model.UserConfig, ghClient *github.Client) error {
-	// Choose repo
-	repoFull, err := cli.Choose("Choose the repo", cfg.Repos)
+func createNewProject(cfg *model.UserConfig, opts newOptions) error {
+	// Choose repo. Any repo can be given with -repo; the configured ones are
+	// offered when it isn't.
+	repoFull := opts.Repo
+	if repoFull == "" {
+		repos := cfg.RepoNames()
+		if len(repos) == 0 {
+			return fmt.Errorf("no repos configured in ~/.templatamus, pass one with -repo")
+		}
+		var err error
+		repoFull, err = cli.Choose("Choose the repo", repos)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Create the provider for the server hosting the repo
+	src, server, err := newSource(cfg, repoFull, model.ServerConfig{})
 	if err != nil {
 		return err
 	}
-	
-	parts := strings.Split(repoFull, "/")
-	owner, repo := parts[0], parts[1]
 
 	fmt.Printf("You're creating an app from the %s repository\n", repoFull)
 
-	// Choose reference (head, branch, tag)
+	// Choose reference (head, branch, tag or a pinned commit)
 	var ref, commitSHA string
-	choice, err := cli.Choose("Do you want to pull head, branch or tag?", []string{"head", "branch", "tag"})
-	if err != nil {
-		return err
+	var choice string
+	switch {
+	case opts.Head:
+		choice = "head"
+	case opts.Branch != "":
+		choice, ref = "branch", opts.Branch
+	case opts.Tag != "":
+		choice, ref = "tag", opts.Tag
+	case opts.Commit != "":
+		choice, ref = "commit", opts.Commit
+	default:
+		choice, err = cli.Choose("Do you want to pull head, branch or tag?", []string{"head", "branch", "tag"})
+		if err != nil {
+			return err
+		}
 	}
 
 	switch choice {
 	case "head":
-		ref, err = ghClient.GetDefaultBranch(owner, repo)
+		ref, err = src.DefaultBranch()
 		if err != nil {
 			return fmt.Errorf("failed to get default branch: %w", err)
 		}
-		
+
 		// Get the latest commit on the branch
-		commits, err := ghClient.GetCommits(owner, repo, ref, time.Time{})
+		commitSHA, err = src.ResolveRef(ref)
 		if err != nil {
-			return fmt.Errorf("failed to get commits: %w", err)
-		}
-		
-		if len(commits) > 0 {
-			commitSHA = commits[0].SHA
-		} else {
-			return fmt.Errorf("no commits found on branch %s", ref)
+			return fmt.Errorf("failed to resolve branch %s: %w", ref, err)
 		}
-		
+
 	case "branch":
-		branches, err := ghClient.GetBranches(owner, repo)
-		if err != nil {
-			return fmt.Errorf("failed to get branches: %w", err)
+		if ref == "" {
+			branches, err := src.ListBranches()
+			if err != nil {
+				return fmt.Errorf("failed to get branches: %w", err)
+			}
+			ref, err = cli.Choose("Choose a branch", branches)
+			if err != nil {
+				return err
+			}
 		}
-		ref, err = cli.Choose("Choose a branch", branches)
-		if err != nil {
-			return err
-		}
-		
+
 		// Get the latest commit on the branch
-		commits, err := ghClient.GetCommits(owner, repo, ref, time.Time{})
+		commitSHA, err = src.ResolveRef(ref)
 		if err != nil {
-			return fmt.Errorf("failed to get commits: %w", err)
+			return fmt.Errorf("failed to resolve branch %s: %w", ref, err)
 		}
-		
-		if len(commits) > 0 {
-			commitSHA = commits[0].SHA
-		} else {
-			return fmt.Errorf("no commits found on branch %s", ref)
-		}
-		
+
 	case "tag":
-		tags, err := ghClient.GetTags(owner, repo)
-		if err != nil {
-			return fmt.Errorf("failed to get tags: %w", err)
+		if ref == "" {
+			tags, err := src.ListTags()
+			if err != nil {
+				return fmt.Errorf("failed to get tags: %w", err)
+			}
+			if len(tags) == 0 {
+				return fmt.Errorf("no tags found in repository")
+			}
+			ref, err = cli.Choose("Choose a tag to download", tags)
+			if err != nil {
+				return err
+			}
 		}
-		if len(tags) == 0 {
-			return fmt.Errorf("no tags found in repository")
-		}
-		ref, err = cli.Choose("Choose a tag to download", tags)
+
+		// Get the commit SHA that this tag points to
+		commitSHA, err = src.ResolveRef(ref)
 		if err != nil {
-			return err
+			return fmt.Errorf("failed to resolve tag %s: %w", ref, err)
 		}
-		
-		// Get the commit SHA that this tag points to
-		commitSHA, err = getCommitSHAForTag(ghClient, owner, repo, ref)
+
+	case "commit":
+		commitSHA, err = src.ResolveRef(ref)
 		if err != nil {
-			// If we can't get the exact commit SHA, use the tag as a fallback
-			fmt.Printf("Warning: Could not resolve tag to commit: %v\n", err)
-			commitSHA = ref
+			return fmt.Errorf("failed to resolve commit %s: %w", ref, err)
 		}
+		// Pin the full SHA, not an abbreviation that could become ambiguous
+		ref = commitSHA
 	}
 
-	fmt.Printf("You're creating an app from %s@%s (commit: %s)\n", repoFull, ref, commitSHA[:8])
+	// Head follows the default branch like any other branch
+	refType := choice
+	if choice == "head" {
+		refType = model.RefTypeBranch
+	}
+
+	fmt.Printf("You're creating an app from %s@%s (commit: %s)\n", repoFull, ref, shortSHA(commitSHA))
+
+	// Choose destination
+	targetDir := opts.Dest
+	if targetDir == "" {
+		targetDir, err = cli.GetDestinationPath("Where do you want to create the project? (e.g., myrepo, ../foo, ~/projects/bar)")
+		if err != nil {
+			return err
+		}
+	}
 
 	// Download zip
 	fmt.Println("Downloading...")
-	zipData, err := ghClient.DownloadZip(owner, repo, ref)
+	zipData, err := src.DownloadArchive(ref)
 	if err != nil {
 		return fmt.Errorf("failed to download zip: %w", err)
 	}
 
+	// ProjectName defaults to the name of the destination directory
+	vars := make(map[string]string)
+	for name, value := range opts.Vars {
+		vars[name] = value
+	}
+	if _, ok := vars["ProjectName"]; !ok {
+		vars["ProjectName"] = filepath.Base(targetDir)
+	}
+
+	// A local template is recorded by its absolute path, so sync finds it
+	// whatever directory it is run from
+	sourceRepo := repoFull
+	if local, ok := src.(*git.LocalSource); ok {
+		sourceRepo = local.Dir
+	}
+
 	// Create project from zip
 	fmt.Println("Unzipping...")
-	if err := sync.CreateProjectFromZip(zipData, targetDir, repoFull, ref, commitSHA); err != nil {
+	if err := sync.CreateProjectFromZip(zipData, targetDir, server, sourceRepo, refType, ref, commitSHA, vars, opts.PostCreate); err != nil {
 		return fmt.Errorf("failed to create project: %w", err)
 	}
 
 	// Initialize git repository if requested
-	ok, err := cli.Confirm("Do you want to init a git repo and initial commit?", true)
-	if err != nil {
-		return err
+	var ok bool
+	if opts.GitInit != nil {
+		ok = *opts.GitInit
+	} else {
+		ok, err = cli.Confirm("Do you want to init a git repo and initial commit?", true)
+		if err != nil {
+			return err
+		}
 	}
 
 	if ok {
@@ -202,7 +228,19 @@ func createNewProject(targetDir string, cfg *model.UserConfig, ghClient *github.
 			return fmt.Errorf("git init failed: %w", err)
 		}
 		fmt.Printf("Committed: %s\n", commitMsg)
+
+		if err := sync.TrackUpstream(targetDir, zipData, commitSHA); err != nil {
+			return fmt.Errorf("failed to record template baseline: %w", err)
+		}
 	}
 
 	return nil
-} 
\ No newline at end of file
+}
+
+// shortSHA returns the abbreviated form of a commit SHA
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
@@ -2,7 +2,10 @@ module templatamus
 
 go 1.24.1
 
-require github.com/AlecAivazis/survey/v2 v2.3.7
+require (
+	github.com/AlecAivazis/survey/v2 v2.3.7
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
 	github.com/kballard/go-shellquote v0.0.0-20180428030007-95032a82bc51 // indirect
@@ -2,8 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"os/exec"
 	"os/user"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/AlecAivazis/survey/v2"
@@ -54,16 +57,62 @@ func Confirm(prompt string, defaultYes bool) (bool, error) {
 	return result, survey.AskOne(q, &result)
 }
 
-// ExpandPath expands the ~ character to the user's home directory
-func ExpandPath(path string) (string, error) {
-	if strings.HasPrefix(path, "~") {
-		u, err := user.Current()
-		if err != nil {
-			return "", err
+// AskVariable prompts for the value of a template variable, checking the answer with validate
+func AskVariable(v model.TemplateVariable, defaultValue string, validate func(string) error) (string, error) {
+	message := v.Name
+	if v.Description != "" {
+		message = fmt.Sprintf("%s (%s)", v.Description, v.Name)
+	}
+
+	switch v.Type {
+	case model.VariableTypeBool:
+		defaultYes, _ := strconv.ParseBool(defaultValue)
+		ok, err := Confirm(message, defaultYes)
+		return strconv.FormatBool(ok), err
+	case model.VariableTypeChoice:
+		var result string
+		q := &survey.Select{
+			Message: message,
+			Options: v.Choices,
+		}
+		if defaultValue != "" {
+			q.Default = defaultValue
+		}
+		return result, survey.AskOne(q, &result)
+	default:
+		var result string
+		q := &survey.Input{
+			Message: message,
+			Default: defaultValue,
 		}
-		return filepath.Join(u.HomeDir, path[1:]), nil
+		validator := func(ans interface{}) error {
+			return validate(ans.(string))
+		}
+		return result, survey.AskOne(q, &result, survey.WithValidator(validator))
+	}
+}
+
+// OpenEditor opens the given files in $VISUAL or $EDITOR, falling back to vi, and
+// waits for the editor to exit
+func OpenEditor(files ...string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Run through the shell so editors configured with flags, like "code --wait", work
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor)
+	cmd.Args = append(cmd.Args, files...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %w", editor, err)
 	}
-	return path, nil
+	return nil
 }
 
 // DisplayCommits shows a list of commits with their status
@@ -74,6 +123,8 @@ func DisplayCommits(commits []model.CommitInfo) {
 		appliedStatus := ""
 		if commit.IsApplied {
 			appliedStatus = "[APPLIED]"
+		} else if commit.SkipReason != "" {
+			appliedStatus = "[SKIPPED: " + commit.SkipReason + "]"
 		}
 		fmt.Printf("%d. %s %s\n   %s by %s on %s\n\n", 
 			i+1, 
@@ -95,6 +146,8 @@ func ChooseCommits(commits []model.CommitInfo) ([]model.CommitInfo, error) {
 		status := ""
 		if commit.IsApplied {
 			status = "[APPLIED] "
+		} else if commit.SkipReason != "" {
+			status = "[SKIPPED] "
 		}
 		options[i] = fmt.Sprintf("%s %s%s", 
 			commit.SHA[:8], 
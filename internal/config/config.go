@@ -15,9 +15,11 @@ const (
 	metadataDir  = ".templatamus"
 	metadataFile = "metadata.json"
 	syncFile     = "sync.json"
+	conflictFile = "conflict.patch"
 )
 
-// LoadUserConfig loads the user's configuration from ~/.templatamus
+// LoadUserConfig loads the user's configuration from ~/.templatamus. A missing
+// file is an empty configuration, as the token can come from elsewhere.
 func LoadUserConfig() (*model.UserConfig, error) {
 	u, err := user.Current()
 	if err != nil {
@@ -25,6 +27,9 @@ func LoadUserConfig() (*model.UserConfig, error) {
 	}
 	path := filepath.Join(u.HomeDir, ".templatamus")
 	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return &model.UserConfig{}, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -50,6 +55,12 @@ func LoadProjectMetadata(dir string) (*model.ProjectMetadata, error) {
 		return nil, fmt.Errorf("failed to read metadata: %w", err)
 	}
 
+	// Older layouts are upgraded step by step, and saved with the next write
+	data, err = migrateMetadata(data)
+	if err != nil {
+		return nil, err
+	}
+
 	var metadata model.ProjectMetadata
 	if err := json.Unmarshal(data, &metadata); err != nil {
 		return nil, fmt.Errorf("failed to parse metadata: %w", err)
@@ -71,22 +82,28 @@ func SaveProjectMetadata(dir string, metadata *model.ProjectMetadata) error {
 		return fmt.Errorf("failed to marshal metadata: %w", err)
 	}
 
-	if err := os.WriteFile(metadataPath, data, 0644); err != nil {
+	if err := writeFileAtomic(metadataPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write metadata: %w", err)
 	}
 
 	return nil
 }
 
-// CreateInitialMetadata creates the initial metadata for a new project
-func CreateInitialMetadata(dir, repo, branch, commit string) error {
+// CreateInitialMetadata creates the initial metadata for a new project created
+// from the given ref on server, which resolved to commit
+func CreateInitialMetadata(dir string, server model.ServerConfig, repo, refType, ref, commit string, vars map[string]string) error {
 	metadata := &model.ProjectMetadata{
-		SourceRepo:     repo,
-		SourceBranch:   branch,
-		SourceCommit:   commit,
-		CreatedAt:      time.Now(),
-		LastSyncedAt:   time.Now(),
-		AppliedCommits: []string{commit},
+		SchemaVersion:     model.MetadataSchemaVersion,
+		SourceAPIURL:      server.APIURL,
+		SourceDownloadURL: server.DownloadURL,
+		SourceRepo:        repo,
+		RefType:           refType,
+		Ref:               ref,
+		ResolvedSHA:       commit,
+		CreatedAt:         time.Now(),
+		LastSyncedAt:      time.Now(),
+		AppliedCommits:    []string{commit},
+		Variables:         vars,
 	}
 
 	return SaveProjectMetadata(dir, metadata)
@@ -127,7 +144,7 @@ func SaveSyncStatus(dir string, status *model.SyncStatus) error {
 		return fmt.Errorf("failed to marshal sync status: %w", err)
 	}
 
-	if err := os.WriteFile(syncPath, data, 0644); err != nil {
+	if err := writeFileAtomic(syncPath, data, 0644); err != nil {
 		return fmt.Errorf("failed to write sync status: %w", err)
 	}
 
@@ -141,4 +158,83 @@ func ClearSyncStatus(dir string) error {
 		return nil
 	}
 	return os.Remove(syncPath)
-} 
\ No newline at end of file
+} 
+
+// SaveConflictPatch saves the diff of a commit that didn't merge cleanly
+func SaveConflictPatch(dir string, diff []byte) error {
+	metadataDir := filepath.Join(dir, metadataDir)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	if err := writeFileAtomic(filepath.Join(metadataDir, conflictFile), diff, 0644); err != nil {
+		return fmt.Errorf("failed to save patch file: %w", err)
+	}
+	return nil
+}
+
+// LoadConflictPatch loads the diff of the commit a sync stopped at
+func LoadConflictPatch(dir string) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(dir, metadataDir, conflictFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch file: %w", err)
+	}
+	return data, nil
+}
+
+// RemoveConflictPatch removes the saved conflict patch, if there is one
+func RemoveConflictPatch(dir string) error {
+	err := os.Remove(filepath.Join(dir, metadataDir, conflictFile))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove patch file: %w", err)
+	}
+	return nil
+}
+
+// MetadataFile returns the path of metadata.json relative to the project
+func MetadataFile() string {
+	return filepath.ToSlash(filepath.Join(metadataDir, metadataFile))
+}
+
+// SyncStateFiles returns the paths, relative to the project, of the files that
+// only exist while a sync or another templatamus run is in progress and must
+// never be committed
+func SyncStateFiles() []string {
+	return []string{
+		filepath.ToSlash(filepath.Join(metadataDir, syncFile)),
+		filepath.ToSlash(filepath.Join(metadataDir, conflictFile)),
+		filepath.ToSlash(filepath.Join(metadataDir, lockFile)),
+	}
+}
+
+// writeFileAtomic replaces the file at path with data, so readers and crashes
+// see either the old or the new contents, never a partial write: the data is
+// written to a temporary file in the same directory, synced to disk and then
+// renamed over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Don't leave the temporary file behind if any step fails
+	defer func() {
+		if err != nil {
+			f.Close()
+			os.Remove(f.Name())
+		}
+	}()
+
+	if _, err = f.Write(data); err != nil {
+		return err
+	}
+	if err = f.Chmod(perm); err != nil {
+		return err
+	}
+	if err = f.Sync(); err != nil {
+		return err
+	}
+	if err = f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
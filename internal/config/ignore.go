@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"templatamus/internal/ignore"
+)
+
+// IgnoreFile is the name of the file at the root of a project listing, in
+// gitignore syntax, the paths sync must never change
+const IgnoreFile = ".templatamusignore"
+
+// LoadIgnoreRules returns the sync ignore rules of the project at dir: the
+// template's defaults followed by the patterns of the project's IgnoreFile,
+// which can re-include paths the defaults ignore
+func LoadIgnoreRules(dir string, defaults []string) (*ignore.Rules, error) {
+	rules := &ignore.Rules{}
+	rules.Add(defaults...)
+
+	data, err := os.ReadFile(filepath.Join(dir, IgnoreFile))
+	if os.IsNotExist(err) {
+		return rules, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", IgnoreFile, err)
+	}
+	rules.Add(strings.Split(string(data), "\n")...)
+	return rules, nil
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// lockFile is created in the metadata directory while a templatamus run changes the project
+	lockFile = "lock"
+	// takeoverSuffix names the guard a run holds while it removes a stale lock
+	takeoverSuffix = ".takeover"
+	// takeoverWait is how long to wait before looking at the lock again while
+	// another run takes it over
+	takeoverWait = 10 * time.Millisecond
+)
+
+// ErrLocked is returned when another templatamus run holds a project's lock
+var ErrLocked = errors.New("project is locked")
+
+// lockOwner identifies the run holding a project's lock
+type lockOwner struct {
+	PID      int       `json:"pid"`
+	Host     string    `json:"host"`
+	LockedAt time.Time `json:"locked_at"`
+}
+
+// ProjectLock is an advisory lock on a project's .templatamus directory, so two
+// templatamus runs, such as a git hook and a manual sync, can't interleave
+// their changes to the metadata and the sync session
+type ProjectLock struct {
+	path string
+}
+
+// LockProject takes the lock on the project at dir. A lock left behind by a
+// run on this host that is no longer alive is taken over; any other lock
+// fails with ErrLocked.
+func LockProject(dir string) (*ProjectLock, error) {
+	metadataDir := filepath.Join(dir, metadataDir)
+	if err := os.MkdirAll(metadataDir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get host name: %w", err)
+	}
+	owner, err := json.Marshal(lockOwner{PID: os.Getpid(), Host: host, LockedAt: time.Now()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal lock owner: %w", err)
+	}
+
+	path := filepath.Join(metadataDir, lockFile)
+	for {
+		err := createLock(path, owner)
+		if err == nil {
+			return &ProjectLock{path: path}, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock file: %w", err)
+		}
+
+		data, holder, err := readLock(path)
+		if os.IsNotExist(err) {
+			// Released in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s can't be read (%v), remove it if no other templatamus run is active", ErrLocked, path, err)
+		}
+		if holder.Host != host || processAlive(holder.PID) {
+			return nil, fmt.Errorf("%w by templatamus (pid %d on %s) since %s, wait for it to finish or remove %s if it is no longer running",
+				ErrLocked, holder.PID, holder.Host, holder.LockedAt.Format(time.RFC3339), path)
+		}
+
+		removed, err := removeStaleLock(path, data, owner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to remove stale lock: %w", err)
+		}
+		if removed {
+			fmt.Printf("Removed stale lock left by pid %d.\n", holder.PID)
+		}
+	}
+}
+
+// removeStaleLock removes the lock at path if it still holds data, the lock of
+// a dead run read earlier. Two runs can both find the same dead owner, and the
+// slower one must not remove the lock the faster one creates after taking over,
+// so only the run holding the takeover guard may remove a lock, and it checks
+// the lock is still the stale one first. It reports whether the stale lock was
+// removed; either way the caller tries again.
+func removeStaleLock(path string, data, owner []byte) (bool, error) {
+	guard := path + takeoverSuffix
+	if err := createLock(guard, owner); err != nil {
+		if !os.IsExist(err) {
+			return false, err
+		}
+		return false, waitForTakeover(guard)
+	}
+	defer os.Remove(guard)
+
+	current, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if !bytes.Equal(current, data) {
+		// Another run took the lock over in the meantime
+		return false, nil
+	}
+	return true, os.Remove(path)
+}
+
+// waitForTakeover waits briefly while another run holds the takeover guard. A
+// guard left by a run that died during the takeover isn't removed, as runs
+// doing so could race each other the same way.
+func waitForTakeover(guard string) error {
+	host, _ := os.Hostname()
+	_, taker, err := readLock(guard)
+	if err == nil && taker.Host == host && !processAlive(taker.PID) {
+		return fmt.Errorf("%w: %s was left by a run that is no longer alive, remove it and try again", ErrLocked, guard)
+	}
+	// The guard may still be being written, or was just removed
+	time.Sleep(takeoverWait)
+	return nil
+}
+
+// Unlock releases the lock
+func (l *ProjectLock) Unlock() error {
+	if err := os.Remove(l.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove lock file: %w", err)
+	}
+	return nil
+}
+
+// createLock creates the lock file with the owner's details, failing if it exists
+func createLock(path string, owner []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(owner); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return err
+	}
+	return nil
+}
+
+// readLock returns the contents of a lock file and the owner recorded in it
+func readLock(path string) ([]byte, *lockOwner, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	var owner lockOwner
+	if err := json.Unmarshal(data, &owner); err != nil {
+		return nil, nil, err
+	}
+	return data, &owner, nil
+}
@@ -0,0 +1,15 @@
+//go:build !unix
+
+package config
+
+import "os"
+
+// processAlive reports whether a process with the given PID runs on this host.
+// Signal 0 isn't supported here, but on Windows FindProcess fails for PIDs no
+// process has. Elsewhere it always succeeds, so every lock holder counts as
+// alive and a stale lock has to be removed by hand rather than taken over
+// from a live run.
+func processAlive(pid int) bool {
+	_, err := os.FindProcess(pid)
+	return err == nil
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// deadPID returns the PID of a process that has exited
+func deadPID(t *testing.T) int {
+	t.Helper()
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("can't run a process to get a dead PID: %v", err)
+	}
+	return cmd.Process.Pid
+}
+
+// holdLock writes a lock file for dir as if the given run held it
+func holdLock(t *testing.T, dir string, owner lockOwner) string {
+	t.Helper()
+	path := filepath.Join(dir, metadataDir, lockFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLockProject(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		holder     *lockOwner
+		wantLocked bool
+	}{
+		{name: "unlocked"},
+		{name: "live holder", holder: &lockOwner{PID: os.Getpid(), Host: host}, wantLocked: true},
+		{name: "stale holder on this host", holder: &lockOwner{PID: deadPID(t), Host: host}},
+		// Whether a run on another host is alive can't be told, so it is never taken over
+		{name: "holder on another host", holder: &lockOwner{PID: deadPID(t), Host: host + ".elsewhere"}, wantLocked: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, metadataDir, lockFile)
+			if tt.holder != nil {
+				tt.holder.LockedAt = time.Now()
+				holdLock(t, dir, *tt.holder)
+			}
+
+			lock, err := LockProject(dir)
+			if tt.wantLocked {
+				if !errors.Is(err, ErrLocked) {
+					t.Fatalf("LockProject error = %v, want ErrLocked", err)
+				}
+				if _, err := os.Stat(path); err != nil {
+					t.Errorf("the holder's lock is gone: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LockProject: %v", err)
+			}
+
+			_, owner, err := readLock(path)
+			if err != nil {
+				t.Fatalf("failed to read lock: %v", err)
+			}
+			if owner.PID != os.Getpid() || owner.Host != host {
+				t.Errorf("lock held by pid %d on %s, want this run", owner.PID, owner.Host)
+			}
+			if err := lock.Unlock(); err != nil {
+				t.Fatalf("Unlock: %v", err)
+			}
+			if _, err := os.Stat(path); !os.IsNotExist(err) {
+				t.Errorf("lock file still exists after Unlock: %v", err)
+			}
+		})
+	}
+}
+
+func TestLockProjectTakeoverRace(t *testing.T) {
+	host, err := os.Hostname()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	holdLock(t, dir, lockOwner{PID: deadPID(t), Host: host, LockedAt: time.Now()})
+
+	// The runs share this process's PID, so once one takes the stale lock over
+	// the others find a live holder
+	const runs = 8
+	var wg sync.WaitGroup
+	results := make(chan error, runs)
+	for i := 0; i < runs; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := LockProject(dir)
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	holders := 0
+	for err := range results {
+		switch {
+		case err == nil:
+			holders++
+		case !errors.Is(err, ErrLocked):
+			t.Errorf("LockProject: %v", err)
+		}
+	}
+	if holders != 1 {
+		t.Errorf("%d runs hold the lock, want 1", holders)
+	}
+}
+
+func TestRemoveStaleLockKeepsNewLock(t *testing.T) {
+	dir := t.TempDir()
+	stale := []byte(`{"pid":1,"host":"a"}`)
+	path := holdLock(t, dir, lockOwner{PID: 2, Host: "b"})
+
+	// The lock changed since it was read as stale, so another run took it over
+	removed, err := removeStaleLock(path, stale, []byte(`{"pid":3,"host":"c"}`))
+	if err != nil {
+		t.Fatalf("removeStaleLock: %v", err)
+	}
+	if removed {
+		t.Error("removeStaleLock removed a lock that isn't the stale one")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("the new lock is gone: %v", err)
+	}
+	if _, err := os.Stat(path + takeoverSuffix); !os.IsNotExist(err) {
+		t.Errorf("the takeover guard was left behind: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+	"templatamus/internal/model"
+)
+
+// ManifestFile is the name of the manifest a template repository can declare at its root
+const ManifestFile = "templatamus.yaml"
+
+// LoadTemplateManifest loads the template manifest from the given directory.
+// It returns nil if the template doesn't declare one.
+func LoadTemplateManifest(dir string) (*model.TemplateManifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFile))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", ManifestFile, err)
+	}
+
+	var manifest model.TemplateManifest
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", ManifestFile, err)
+	}
+
+	seen := make(map[string]bool)
+	for i, v := range manifest.Variables {
+		if v.Name == "" {
+			return nil, fmt.Errorf("invalid %s: variable %d has no name", ManifestFile, i+1)
+		}
+		if seen[v.Name] {
+			return nil, fmt.Errorf("invalid %s: variable %s is declared twice", ManifestFile, v.Name)
+		}
+		seen[v.Name] = true
+
+		switch v.Type {
+		case "":
+			manifest.Variables[i].Type = model.VariableTypeString
+		case model.VariableTypeString, model.VariableTypeBool:
+		case model.VariableTypeChoice:
+			if len(v.Choices) == 0 {
+				return nil, fmt.Errorf("invalid %s: variable %s has no choices", ManifestFile, v.Name)
+			}
+			if v.Default != "" && !slices.Contains(v.Choices, v.Default) {
+				return nil, fmt.Errorf("invalid %s: default %q of variable %s is not one of its choices %v", ManifestFile, v.Default, v.Name, v.Choices)
+			}
+		default:
+			return nil, fmt.Errorf("invalid %s: variable %s has unknown type %q", ManifestFile, v.Name, v.Type)
+		}
+
+		if v.Validation != "" {
+			if _, err := regexp.Compile(v.Validation); err != nil {
+				return nil, fmt.Errorf("invalid %s: variable %s has a bad validation pattern: %w", ManifestFile, v.Name, err)
+			}
+		}
+	}
+
+	return &manifest, nil
+}
+
+// ValidateVariable checks a value against the variable's type, choices and validation pattern
+func ValidateVariable(v model.TemplateVariable, value string) error {
+	switch v.Type {
+	case model.VariableTypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("%s must be true or false, got %q", v.Name, value)
+		}
+	case model.VariableTypeChoice:
+		if !slices.Contains(v.Choices, value) {
+			return fmt.Errorf("%s must be one of %v, got %q", v.Name, v.Choices, value)
+		}
+	}
+
+	if v.Validation != "" {
+		// Anchor the pattern so it has to match the whole value
+		re := regexp.MustCompile("^(?:" + v.Validation + ")$")
+		if !re.MatchString(value) {
+			return fmt.Errorf("%s must match %s, got %q", v.Name, v.Validation, value)
+		}
+	}
+
+	return nil
+}
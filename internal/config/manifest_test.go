@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadTemplateManifest(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			yaml: `variables:
+  - name: database
+    type: choice
+    choices: [postgres, mysql]
+    default: postgres
+  - name: module
+    default: "github.com/org/{{ .ProjectName }}"
+    validation: "[a-z./-]+"
+`,
+		},
+		{
+			name: "choice default not a choice",
+			yaml: `variables:
+  - name: database
+    type: choice
+    choices: [postgres, mysql]
+    default: sqlite
+`,
+			wantErr: `default "sqlite" of variable database is not one of its choices`,
+		},
+		{
+			name: "choice without choices",
+			yaml: `variables:
+  - name: database
+    type: choice
+`,
+			wantErr: "variable database has no choices",
+		},
+		{
+			name: "declared twice",
+			yaml: `variables:
+  - name: module
+  - name: module
+`,
+			wantErr: "variable module is declared twice",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, ManifestFile), []byte(tt.yaml), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			manifest, err := LoadTemplateManifest(dir)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadTemplateManifest: %v", err)
+			}
+			if len(manifest.Variables) != 2 {
+				t.Errorf("got %d variables, want 2", len(manifest.Variables))
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"templatamus/internal/model"
+)
+
+// legacySchemaVersion is the version of metadata written before schema_version was recorded
+const legacySchemaVersion = 1
+
+// metadataMigration upgrades metadata.json, decoded into a map, from one
+// schema version to the next
+type metadataMigration func(raw map[string]any) error
+
+// metadataMigrations holds the step from each schema version to the next,
+// keyed by the version it upgrades from. A change to the layout of
+// metadata.json bumps model.MetadataSchemaVersion and registers its step here,
+// so older files are upgraded one version at a time.
+var metadataMigrations = map[int]metadataMigration{
+	1: migrateRefFields,
+}
+
+// commitSHA matches a full SHA-1 or SHA-256 commit ID
+var commitSHA = regexp.MustCompile(`^[0-9a-f]{40}([0-9a-f]{24})?$`)
+
+// migrateMetadata upgrades the raw contents of metadata.json to the current
+// schema version. Metadata written by a newer version of templatamus is
+// refused, as saving it again would drop whatever this version doesn't know.
+func migrateMetadata(data []byte) ([]byte, error) {
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse metadata: %w", err)
+	}
+
+	version := legacySchemaVersion
+	if v, ok := raw["schema_version"].(float64); ok {
+		version = int(v)
+	}
+	if version > model.MetadataSchemaVersion {
+		return nil, fmt.Errorf("metadata was written by a newer version of templatamus (schema version %d, this version supports up to %d), please upgrade templatamus",
+			version, model.MetadataSchemaVersion)
+	}
+	if version == model.MetadataSchemaVersion {
+		return data, nil
+	}
+
+	for ; version < model.MetadataSchemaVersion; version++ {
+		migrate, ok := metadataMigrations[version]
+		if !ok {
+			return nil, fmt.Errorf("no migration for metadata schema version %d", version)
+		}
+		if err := migrate(raw); err != nil {
+			return nil, fmt.Errorf("failed to migrate metadata from schema version %d: %w", version, err)
+		}
+	}
+	raw["schema_version"] = model.MetadataSchemaVersion
+
+	return json.Marshal(raw)
+}
+
+// migrateRefFields replaces source_branch and source_commit with ref_type, ref
+// and resolved_sha. source_branch held whatever ref the project was created
+// from, so whether it is a branch or a tag is left for sync to look up. A
+// source commit that isn't a SHA is the name of a tag that couldn't be resolved.
+func migrateRefFields(raw map[string]any) error {
+	branch, _ := raw["source_branch"].(string)
+	commit, _ := raw["source_commit"].(string)
+
+	raw["ref"] = branch
+	if commitSHA.MatchString(commit) {
+		raw["resolved_sha"] = commit
+	} else {
+		raw["ref_type"] = model.RefTypeTag
+	}
+
+	delete(raw, "source_branch")
+	delete(raw, "source_commit")
+	return nil
+}
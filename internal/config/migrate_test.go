@@ -0,0 +1,95 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"templatamus/internal/model"
+)
+
+const testSHA = "0123456789abcdef0123456789abcdef01234567"
+
+// migrate runs migrateMetadata on data and decodes the result
+func migrate(t *testing.T, data string) *model.ProjectMetadata {
+	t.Helper()
+	out, err := migrateMetadata([]byte(data))
+	if err != nil {
+		t.Fatalf("migrateMetadata: %v", err)
+	}
+	var metadata model.ProjectMetadata
+	if err := json.Unmarshal(out, &metadata); err != nil {
+		t.Fatalf("failed to decode migrated metadata: %v", err)
+	}
+	return &metadata
+}
+
+func TestMigrationsCoverEveryVersion(t *testing.T) {
+	for version := legacySchemaVersion; version < model.MetadataSchemaVersion; version++ {
+		if _, ok := metadataMigrations[version]; !ok {
+			t.Errorf("no migration registered from schema version %d", version)
+		}
+	}
+}
+
+func TestMigrateLegacyMetadata(t *testing.T) {
+	metadata := migrate(t, `{
+		"source_repo": "org/template",
+		"source_branch": "main",
+		"source_commit": "`+testSHA+`",
+		"applied_commits": ["`+testSHA+`"]
+	}`)
+
+	if metadata.SchemaVersion != model.MetadataSchemaVersion {
+		t.Errorf("schema version = %d, want %d", metadata.SchemaVersion, model.MetadataSchemaVersion)
+	}
+	if metadata.Ref != "main" || metadata.ResolvedSHA != testSHA {
+		t.Errorf("ref %q at %q, want main at %s", metadata.Ref, metadata.ResolvedSHA, testSHA)
+	}
+	// Whether the ref is a branch or a tag is looked up by sync
+	if metadata.RefType != "" {
+		t.Errorf("ref type = %q, want it left empty", metadata.RefType)
+	}
+	if metadata.SourceRepo != "org/template" || len(metadata.AppliedCommits) != 1 {
+		t.Errorf("other fields not kept: %+v", metadata)
+	}
+}
+
+func TestMigrateUnresolvedTag(t *testing.T) {
+	// A tag that couldn't be resolved was stored by name as the source commit
+	metadata := migrate(t, `{
+		"source_repo": "org/template",
+		"source_branch": "v1.2.0",
+		"source_commit": "v1.2.0"
+	}`)
+
+	if metadata.RefType != model.RefTypeTag || metadata.Ref != "v1.2.0" {
+		t.Errorf("ref %s %q, want tag v1.2.0", metadata.RefType, metadata.Ref)
+	}
+	if metadata.ResolvedSHA != "" {
+		t.Errorf("resolved SHA = %q, want it left for sync to resolve", metadata.ResolvedSHA)
+	}
+}
+
+func TestMigrateCurrentVersionUnchanged(t *testing.T) {
+	data := fmt.Sprintf(`{"schema_version": %d, "source_repo": "org/template", "ref_type": "branch", "ref": "main", "resolved_sha": "%s", "unknown": true}`,
+		model.MetadataSchemaVersion, testSHA)
+
+	out, err := migrateMetadata([]byte(data))
+	if err != nil {
+		t.Fatalf("migrateMetadata: %v", err)
+	}
+	if string(out) != data {
+		t.Errorf("metadata changed:\n%s\nwant\n%s", out, data)
+	}
+}
+
+func TestMigrateRefusesNewerVersion(t *testing.T) {
+	data := fmt.Sprintf(`{"schema_version": %d, "source_repo": "org/template"}`, model.MetadataSchemaVersion+1)
+
+	_, err := migrateMetadata([]byte(data))
+	if err == nil || !strings.Contains(err.Error(), "newer version of templatamus") {
+		t.Errorf("error = %v, want it to refuse metadata from a newer version", err)
+	}
+}
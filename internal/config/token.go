@@ -0,0 +1,101 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+)
+
+// githubHost is the host GitHub's credentials are stored under, whose API is
+// served from api.github.com
+const githubHost = "github.com"
+
+// githubAPIURL is the API of github.com, the server token in ~/.templatamus is
+// for unless api_url points elsewhere
+const githubAPIURL = "https://api." + githubHost
+
+// Token is an API token along with where it was found
+type Token struct {
+	Value string
+	// Source names where the token came from, such as GITHUB_TOKEN or gh auth token
+	Source string
+}
+
+// ResolveToken finds the token for the server at apiURL, trying in turn:
+//
+//   - the TEMPLATAMUS_TOKEN environment variable, for any host
+//   - the GITHUB_TOKEN environment variable, for github.com only
+//   - the token gh is logged in with for the host
+//   - the password git's credential helpers store for the host
+//   - the host's entry in tokens in ~/.templatamus
+//   - the token in ~/.templatamus, for the GitHub server its api_url names only
+//
+// A source that fails, such as gh not being installed, is skipped. The
+// returned token is empty if no source has one.
+func ResolveToken(cfg *model.UserConfig, apiURL string) Token {
+	if token := os.Getenv("TEMPLATAMUS_TOKEN"); token != "" {
+		return Token{Value: token, Source: "TEMPLATAMUS_TOKEN"}
+	}
+
+	scheme, host, hostname, ok := parseHost(apiURL)
+	if !ok {
+		return Token{}
+	}
+
+	// GITHUB_TOKEN is issued by github.com, so it isn't sent anywhere else
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && host == githubHost {
+		return Token{Value: token, Source: "GITHUB_TOKEN"}
+	}
+	if token := ghToken(hostname); token != "" {
+		return Token{Value: token, Source: "gh auth token"}
+	}
+	if token, err := git.CredentialFill(scheme, host); err == nil && token != "" {
+		return Token{Value: token, Source: "git credential"}
+	}
+
+	if token, ok := cfg.Tokens[host]; ok {
+		return Token{Value: token, Source: "~/.templatamus (tokens." + host + ")"}
+	}
+
+	// The global token is a GitHub token, so like GITHUB_TOKEN it isn't sent
+	// to GitLab or any other server
+	githubURL := cfg.APIURL
+	if githubURL == "" {
+		githubURL = githubAPIURL
+	}
+	if _, githubServer, _, ok := parseHost(githubURL); ok && host == githubServer && cfg.Token != "" {
+		return Token{Value: cfg.Token, Source: "~/.templatamus"}
+	}
+	return Token{}
+}
+
+// parseHost parses an API URL and returns its scheme, the host its credentials
+// are stored under, which keeps the port as git credentials do, and the host
+// name gh takes. api.github.com is looked up as github.com.
+func parseHost(apiURL string) (string, string, string, bool) {
+	u, err := url.Parse(apiURL)
+	if err != nil || u.Host == "" {
+		return "", "", "", false
+	}
+	if u.Host == "api."+githubHost {
+		return u.Scheme, githubHost, githubHost, true
+	}
+	return u.Scheme, u.Host, u.Hostname(), true
+}
+
+// ghToken returns the token the GitHub CLI is logged in with for host, or ""
+// if gh isn't installed or not logged in there
+func ghToken(host string) string {
+	if _, err := exec.LookPath("gh"); err != nil {
+		return ""
+	}
+	out, err := exec.Command("gh", "auth", "token", "--hostname", host).Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"templatamus/internal/model"
+)
+
+// fakeTools puts stand-ins for gh and git on PATH, which answer with a token
+// naming the host they were asked about, or fail the way the real ones do
+// when they have no token if they are turned off
+func fakeTools(t *testing.T, gh, git bool) {
+	t.Helper()
+	bin := t.TempDir()
+	scripts := map[string]string{}
+	if gh {
+		// gh auth token --hostname <host>
+		scripts["gh"] = "#!/bin/sh\necho \"gh-$4\"\n"
+	} else {
+		scripts["gh"] = "#!/bin/sh\necho 'not logged in' >&2\nexit 1\n"
+	}
+	if git {
+		// git credential fill, reading protocol=...\nhost=...\n from stdin
+		scripts["git"] = "#!/bin/sh\nwhile read -r line; do case $line in host=*) host=${line#host=};; esac; done\necho \"password=git-$host\"\n"
+	} else {
+		scripts["git"] = "#!/bin/sh\necho 'fatal: could not read Username: terminal prompts disabled' >&2\nexit 128\n"
+	}
+	for name, script := range scripts {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin)
+}
+
+func TestResolveTokenOrder(t *testing.T) {
+	cfg := &model.UserConfig{
+		Token:  "global",
+		Tokens: map[string]string{"gitlab.com": "gitlab-entry", "ghe.example.com": "ghe-entry"},
+	}
+	ghe := &model.UserConfig{Token: "global", ServerConfig: model.ServerConfig{APIURL: "https://ghe.example.com/api/v3"}}
+
+	tests := []struct {
+		name          string
+		cfg           *model.UserConfig
+		apiURL        string
+		templatamus   string
+		github        string
+		gh, git       bool
+		want, wantSrc string
+	}{
+		{name: "TEMPLATAMUS_TOKEN first", cfg: cfg, apiURL: "https://gitlab.com/api/v4", templatamus: "tt", github: "gt", gh: true, git: true, want: "tt", wantSrc: "TEMPLATAMUS_TOKEN"},
+		{name: "GITHUB_TOKEN for github.com", cfg: cfg, apiURL: "https://api.github.com", github: "gt", gh: true, git: true, want: "gt", wantSrc: "GITHUB_TOKEN"},
+		{name: "GITHUB_TOKEN not for other hosts", cfg: cfg, apiURL: "https://gitlab.com/api/v4", github: "gt", gh: true, git: true, want: "gh-gitlab.com", wantSrc: "gh auth token"},
+		{name: "gh asked for github.com", cfg: cfg, apiURL: "https://api.github.com", gh: true, git: true, want: "gh-github.com", wantSrc: "gh auth token"},
+		{name: "git credential after gh", cfg: cfg, apiURL: "https://gitlab.example.com:8443/api/v4", git: true, want: "git-gitlab.example.com:8443", wantSrc: "git credential"},
+		{name: "tokens entry", cfg: cfg, apiURL: "https://gitlab.com/api/v4", want: "gitlab-entry", wantSrc: "~/.templatamus (tokens.gitlab.com)"},
+		{name: "global token for github.com", cfg: cfg, apiURL: "https://api.github.com", want: "global", wantSrc: "~/.templatamus"},
+		{name: "global token for its api_url", cfg: ghe, apiURL: "https://ghe.example.com/api/v3", want: "global", wantSrc: "~/.templatamus"},
+		{name: "global token not for github.com when api_url is elsewhere", cfg: ghe, apiURL: "https://api.github.com"},
+		{name: "global token not for other hosts", cfg: cfg, apiURL: "https://gitea.example.com/api/v1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEMPLATAMUS_TOKEN", tt.templatamus)
+			t.Setenv("GITHUB_TOKEN", tt.github)
+			fakeTools(t, tt.gh, tt.git)
+
+			got := ResolveToken(tt.cfg, tt.apiURL)
+			if got.Value != tt.want || got.Source != tt.wantSrc {
+				t.Errorf("ResolveToken(%s) = %q from %q, want %q from %q", tt.apiURL, got.Value, got.Source, tt.want, tt.wantSrc)
+			}
+		})
+	}
+}
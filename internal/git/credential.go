@@ -0,0 +1,43 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// CredentialFill asks git's credential helpers for the password stored for a
+// server, the way git would before talking to it over HTTP. It returns "" if
+// no helper has one. Git is kept from prompting, so this never blocks on the
+// terminal or opens an askpass window.
+func CredentialFill(protocol, host string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", "credential", "fill")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("protocol=%s\nhost=%s\n\n", protocol, host))
+	// An empty GIT_ASKPASS stops git from falling back to core.askPass or SSH_ASKPASS
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0", "GIT_ASKPASS=", "GCM_INTERACTIVE=never")
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		// Without a stored credential git fails at the disabled prompt
+		if strings.Contains(stderr.String(), "terminal prompts disabled") {
+			return "", nil
+		}
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", fmt.Errorf("git credential fill: %w", err)
+		}
+		return "", fmt.Errorf("git credential fill: %w: %s", err, msg)
+	}
+
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if password, ok := strings.CutPrefix(scanner.Text(), "password="); ok {
+			return password, nil
+		}
+	}
+	return "", nil
+}
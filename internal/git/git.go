@@ -5,9 +5,13 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+
+	"templatamus/internal/model"
 )
 
 // InitRepo initializes a git repository in the specified directory
@@ -30,18 +34,19 @@ func InitRepo(dir, msg string) error {
 }
 
 // ApplyDiff applies a diff to the repository
-// Returns true if the diff was applied successfully, false if there are conflicts
-func ApplyDiff(dir string, diff []byte) (bool, error) {
+// Returns the files with hunks that didn't apply, which are left in .rej files
+// next to them, or nil if the diff was applied successfully
+func ApplyDiff(dir string, diff []byte) ([]model.RejectedFile, error) {
 	// Write diff to a temporary file
 	tmpFile, err := os.CreateTemp("", "templatamus-diff-*.patch")
 	if err != nil {
-		return false, fmt.Errorf("failed to create temp file: %w", err)
+		return nil, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	defer os.Remove(tmpFile.Name())
 	defer tmpFile.Close()
 
 	if _, err := tmpFile.Write(diff); err != nil {
-		return false, fmt.Errorf("failed to write diff: %w", err)
+		return nil, fmt.Errorf("failed to write diff: %w", err)
 	}
 	tmpFile.Close()
 
@@ -55,24 +60,133 @@ func ApplyDiff(dir string, diff []byte) (bool, error) {
 		// Check if there were conflicts
 		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
 			// Look for .rej files to determine if there were actual conflicts
-			rejFiles, err := filepath.Glob(filepath.Join(dir, "*.rej"))
+			rejects, err := FindRejects(dir)
 			if err != nil {
-				return false, fmt.Errorf("failed to check for .rej files: %w", err)
+				return nil, err
 			}
-			if len(rejFiles) > 0 {
-				return false, nil // Conflicts detected
+			if len(rejects) > 0 {
+				return rejects, nil // Conflicts detected
 			}
 		}
-		return false, fmt.Errorf("failed to apply patch: %w", err)
+		return nil, fmt.Errorf("failed to apply patch: %w", err)
+	}
+
+	return nil, nil
+}
+
+// FindRejects returns every file below dir with a .rej file next to it and the
+// number of hunks it rejected. .rej files the project tracks are its own and
+// aren't reported.
+func FindRejects(dir string) ([]model.RejectedFile, error) {
+	out, err := runGit(dir, "ls-files", "-z", "--", "*.rej")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tracked .rej files: %w", err)
+	}
+	tracked := make(map[string]bool)
+	for _, path := range strings.Split(string(out), "\x00") {
+		tracked[path] = true
 	}
 
-	return true, nil
+	var rejects []model.RejectedFile
+	err = walkRejects(dir, func(path string) error {
+		rel, err := filepath.Rel(dir, strings.TrimSuffix(path, ".rej"))
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if tracked[rel+".rej"] {
+			return nil
+		}
+
+		rejected, err := readReject(dir, rel)
+		if err != nil {
+			return err
+		}
+		rejects = append(rejects, rejected)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check for .rej files: %w", err)
+	}
+	return rejects, nil
 }
 
-// CommitChanges commits the changes with the given message
-func CommitChanges(dir, msg string) error {
+// readReject reads the .rej file next to the file at the slash-separated path
+// and counts the hunks it rejected
+func readReject(dir, path string) (model.RejectedFile, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)+".rej"))
+	if err != nil {
+		return model.RejectedFile{}, err
+	}
+
+	// A reject without hunk headers, such as a binary patch, rejects the file as a whole
+	hunks := 0
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.HasPrefix(line, "@@ ") {
+			hunks++
+		}
+	}
+	if hunks == 0 {
+		hunks = 1
+	}
+	return model.RejectedFile{Path: path, Hunks: hunks}, nil
+}
+
+// RemoveRejects deletes the .rej files next to the files at the given
+// slash-separated paths. .rej files the project tracks are left alone.
+func RemoveRejects(dir string, paths []string) error {
+	for _, path := range paths {
+		rej := path + ".rej"
+		tracked, err := IsTracked(dir, rej)
+		if err != nil {
+			return err
+		}
+		if tracked {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, filepath.FromSlash(rej))); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", rej, err)
+		}
+	}
+	return nil
+}
+
+// walkRejects calls fn for each .rej file below dir, skipping the .git directory
+func walkRejects(dir string, fn func(path string) error) error {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if d.Type().IsRegular() && strings.HasSuffix(d.Name(), ".rej") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range paths {
+		if err := fn(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CommitChanges commits the changes with the given message, leaving out the
+// excluded paths
+func CommitChanges(dir, msg string, exclude ...string) error {
 	// Add all changes
-	cmd := exec.Command("git", "add", ".")
+	args := []string{"add", "--all", "--", "."}
+	for _, path := range exclude {
+		args = append(args, ":(exclude)"+path)
+	}
+	cmd := exec.Command("git", args...)
 	cmd.Dir = dir
 	if err := cmd.Run(); err != nil {
 		return fmt.Errorf("git add failed: %w", err)
@@ -99,7 +213,8 @@ func CommitChanges(dir, msg string) error {
 	return nil
 }
 
-// ExtractZip extracts a zip file to the specified directory
+// ExtractZip extracts a zip file to the specified directory, keeping the
+// permissions of its files and its symlinks
 func ExtractZip(data []byte, dir string) error {
 	r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
 	if err != nil {
@@ -119,8 +234,27 @@ func ExtractZip(data []byte, dir string) error {
 		if err != nil {
 			return err
 		}
-		
-		out, err := os.Create(fpath)
+
+		// Symlinks are stored with their target as the content
+		if f.Mode()&os.ModeSymlink != 0 {
+			target, err := io.ReadAll(in)
+			in.Close()
+			if err != nil {
+				return err
+			}
+			if err := os.Symlink(string(target), fpath); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Keep the file's permissions, so executable scripts stay executable.
+		// Archives made without them get the default ones.
+		perm := f.Mode().Perm()
+		if perm == 0 {
+			perm = 0644
+		}
+		out, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
 		if err != nil {
 			in.Close()
 			return err
@@ -160,9 +294,43 @@ func MoveDirContents(src, dst string) error {
 	return nil
 }
 
-// CheckRepoStatus checks if the repository has uncommitted changes
-func CheckRepoStatus(dir string) (bool, error) {
-	cmd := exec.Command("git", "status", "--porcelain")
+// Head returns the commit HEAD points to
+func Head(dir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--verify", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve HEAD: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResetHard moves the current branch, the index and the working tree to commit
+func ResetHard(dir, commit string) error {
+	if _, err := runGit(dir, "reset", "--hard", "--quiet", commit); err != nil {
+		return fmt.Errorf("failed to reset to %s: %w", commit, err)
+	}
+	return nil
+}
+
+// IsTracked reports whether path is in the index of the repository at dir
+func IsTracked(dir, path string) (bool, error) {
+	out, err := runGit(dir, "ls-files", "--", ":(literal)"+path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check whether %s is tracked: %w", path, err)
+	}
+	return len(out) > 0, nil
+}
+
+// CheckRepoStatus checks if the repository has uncommitted changes, ignoring
+// the paths in exclude
+func CheckRepoStatus(dir string, exclude ...string) (bool, error) {
+	args := []string{"status", "--porcelain"}
+	if len(exclude) > 0 {
+		args = append(args, "--", ".")
+		for _, path := range exclude {
+			args = append(args, ":(exclude)"+path)
+		}
+	}
+	cmd := exec.Command("git", args...)
 	cmd.Dir = dir
 	output, err := cmd.Output()
 	if err != nil {
@@ -0,0 +1,64 @@
+package git
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExtractZipKeepsModes(t *testing.T) {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	files := []struct {
+		name    string
+		mode    os.FileMode
+		content string
+	}{
+		{name: "root/run.sh", mode: 0755, content: "#!/bin/sh\n"},
+		{name: "root/README.md", mode: 0644, content: "# Template\n"},
+		{name: "root/docs", mode: os.ModeSymlink | 0777, content: "README.md"},
+	}
+	for _, f := range files {
+		header := &zip.FileHeader{Name: f.name, Method: zip.Deflate}
+		header.SetMode(f.mode)
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := fw.Write([]byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	if err := ExtractZip(buf.Bytes(), dir); err != nil {
+		t.Fatalf("ExtractZip: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "root", "run.sh"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0100 == 0 {
+		t.Errorf("run.sh has mode %v, want it executable", info.Mode())
+	}
+	info, err = os.Stat(filepath.Join(dir, "root", "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("README.md has mode %v, want it not executable", info.Mode())
+	}
+	target, err := os.Readlink(filepath.Join(dir, "root", "docs"))
+	if err != nil {
+		t.Fatalf("docs is not a symlink: %v", err)
+	}
+	if target != "README.md" {
+		t.Errorf("docs links to %q, want README.md", target)
+	}
+}
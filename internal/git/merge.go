@@ -0,0 +1,229 @@
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"templatamus/internal/model"
+)
+
+// MergeResult describes the files MergeTrees changed and those it couldn't merge cleanly
+type MergeResult struct {
+	// Changed lists every file the merge touched
+	Changed []string
+	// Conflicted lists the files left with conflict markers and unmerged index entries
+	Conflicted []string
+	// Rejected lists the files whose changes couldn't be merged at all and were
+	// written to .rej files instead
+	Rejected []model.RejectedFile
+}
+
+// Clean reports whether every file was merged without conflicts
+func (r *MergeResult) Clean() bool {
+	return len(r.Conflicted) == 0 && len(r.Rejected) == 0
+}
+
+// WriteTree stores the contents of contentDir as a tree object in the repository
+// at dir and returns its ID. The repository's index and working tree are not touched.
+func WriteTree(dir, contentDir string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--absolute-git-dir")
+	if err != nil {
+		return "", fmt.Errorf("failed to find git directory: %w", err)
+	}
+	gitDir := strings.TrimSpace(string(out))
+
+	// A temporary index keeps the project's own index out of the way
+	indexDir, err := os.MkdirTemp("", "templatamus-index-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(indexDir)
+
+	env := []string{
+		"GIT_DIR=" + gitDir,
+		"GIT_WORK_TREE=" + contentDir,
+		"GIT_INDEX_FILE=" + filepath.Join(indexDir, "index"),
+	}
+	// --force adds files the template's .gitignore would otherwise hide
+	if _, err := runGitEnv(contentDir, env, "add", "--all", "--force", "."); err != nil {
+		return "", fmt.Errorf("failed to add files to tree: %w", err)
+	}
+	out, err = runGitEnv(contentDir, env, "write-tree")
+	if err != nil {
+		return "", fmt.Errorf("failed to write tree: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// EmptyTree returns the ID of the empty tree, writing it to the repository at dir
+func EmptyTree(dir string) (string, error) {
+	out, err := runGit(dir, "hash-object", "-w", "-t", "tree", os.DevNull)
+	if err != nil {
+		return "", fmt.Errorf("failed to write empty tree: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// DiffTrees returns the changes between two trees in the repository at dir as
+// a binary git diff, limited to the given paths if there are any
+func DiffTrees(dir, base, target string, paths ...string) ([]byte, error) {
+	args := []string{"diff", "--binary", "--full-index", "--no-renames", base, target}
+	if len(paths) > 0 {
+		args = append(append(args, "--"), paths...)
+	}
+	out, err := runGit(dir, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff trees: %w", err)
+	}
+	return out, nil
+}
+
+// ChangedFiles returns the paths of the files that differ between two trees
+func ChangedFiles(dir, base, target string) ([]string, error) {
+	out, err := runGit(dir, "diff", "--name-only", "-z", "--no-renames", base, target)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changed files: %w", err)
+	}
+
+	var paths []string
+	for _, path := range strings.Split(string(out), "\x00") {
+		if path != "" {
+			paths = append(paths, path)
+		}
+	}
+	return paths, nil
+}
+
+// MergeTrees brings the changes to the given paths between the base and target
+// trees into the working tree at dir with a three-way merge, the way
+// cherry-picking a commit would. Both trees must be in the repository, so git
+// can use the base version of each file when the project changed it. Files are
+// merged one at a time: overlapping changes leave conflict markers that editors
+// and git mergetool understand, and changes git can't merge at all, such as
+// edits to a file the project deleted, fall back to ApplyDiff and leave .rej files.
+func MergeTrees(dir, base, target string, paths []string) (*MergeResult, error) {
+	result := &MergeResult{}
+	for _, path := range paths {
+		result.Changed = append(result.Changed, path)
+
+		diff, err := runGit(dir, "diff", "--binary", "--full-index", "--no-renames", base, target, "--", path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to diff %s: %w", path, err)
+		}
+
+		merged, conflicted, err := mergeFile(dir, path, diff)
+		if err != nil {
+			return nil, err
+		}
+		if merged {
+			if conflicted {
+				result.Conflicted = append(result.Conflicted, path)
+			}
+			continue
+		}
+
+		if err := rejectFile(dir, path, diff); err != nil {
+			return nil, err
+		}
+		rejected, err := readReject(dir, path)
+		if os.IsNotExist(err) {
+			// Everything applied after all
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read rejected changes of %s: %w", path, err)
+		}
+		result.Rejected = append(result.Rejected, rejected)
+	}
+	return result, nil
+}
+
+// mergeFile applies a single file's diff with git apply --3way. It reports
+// whether git could merge the file and whether the merge left conflicts.
+func mergeFile(dir, path string, diff []byte) (bool, bool, error) {
+	cmd := exec.Command("git", "apply", "--3way", "-")
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(diff)
+	// The outcome is reported by the caller, git's own messages are just noise
+	if err := cmd.Run(); err == nil {
+		return true, false, nil
+	} else if _, ok := err.(*exec.ExitError); !ok {
+		return false, false, fmt.Errorf("failed to run git apply: %w", err)
+	}
+
+	// git apply fails both for conflicts and for diffs it can't merge, only
+	// the former leave unmerged entries in the index
+	out, err := runGit(dir, "ls-files", "--unmerged", "--", path)
+	if err != nil {
+		return false, false, fmt.Errorf("failed to check for conflicts in %s: %w", path, err)
+	}
+	if len(out) > 0 {
+		return true, true, nil
+	}
+	return false, false, nil
+}
+
+// rejectFile applies a diff git couldn't merge with ApplyDiff, which writes the
+// hunks that don't apply to .rej files. When even that fails, as it does for
+// changes to a file the project deleted, the whole diff is saved as the file's
+// .rej so it can still be reviewed.
+func rejectFile(dir, path string, diff []byte) error {
+	if _, err := ApplyDiff(dir, diff); err == nil {
+		return nil
+	}
+
+	rejPath := filepath.Join(dir, filepath.FromSlash(path)+".rej")
+	if err := os.MkdirAll(filepath.Dir(rejPath), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", rejPath, err)
+	}
+	if err := os.WriteFile(rejPath, diff, 0644); err != nil {
+		return fmt.Errorf("failed to save rejected changes to %s: %w", rejPath, err)
+	}
+	return nil
+}
+
+// ScratchWorktree checks out HEAD of the repository at dir into a temporary
+// worktree, so merges can be tried out without touching the project. The
+// returned function removes the worktree again.
+func ScratchWorktree(dir string) (string, func(), error) {
+	tempDir, err := os.MkdirTemp("", "templatamus-worktree-")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	path := filepath.Join(tempDir, "tree")
+
+	if _, err := runGit(dir, "worktree", "add", "--detach", "--quiet", path, "HEAD"); err != nil {
+		os.RemoveAll(tempDir)
+		return "", nil, fmt.Errorf("failed to create scratch worktree: %w", err)
+	}
+
+	remove := func() {
+		runGit(dir, "worktree", "remove", "--force", path)
+		os.RemoveAll(tempDir)
+		runGit(dir, "worktree", "prune")
+	}
+	return path, remove, nil
+}
+
+// AcceptTarget resolves the given paths by taking their version from the target
+// tree, deleting those it doesn't contain, removes their .rej files and stages
+// everything, so the next merge starts from a clean index
+func AcceptTarget(dir, tree string, paths []string) error {
+	for _, path := range paths {
+		if err := CheckoutFrom(dir, tree, path); err != nil {
+			return err
+		}
+	}
+
+	if err := RemoveRejects(dir, paths); err != nil {
+		return err
+	}
+	if _, err := runGit(dir, "add", "--all"); err != nil {
+		return fmt.Errorf("failed to stage changes: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+	"templatamus/internal/version"
+)
+
+func init() {
+	provider.Register("git", NewRemoteSource)
+}
+
+const (
+	// initialDeepen is how many commits the first deepening of a shallow fetch adds
+	initialDeepen = 32
+	// maxDeepen is the deepening after which we give up and fetch the full history
+	maxDeepen = 4096
+)
+
+// RemoteSource is a provider.SourceProvider for any git URL, for servers that
+// have no hosting API. Refs are listed with git ls-remote and commits are
+// fetched shallowly into a bare cache repository, where trees, commit lists and
+// diffs are computed locally. Authentication is left to the user's SSH agent
+// or credential helper.
+type RemoteSource struct {
+	URL string
+	// Cache is the bare repository the remote's commits are fetched into
+	Cache string
+
+	refs map[string]string
+}
+
+// NewRemoteSource creates a RemoteSource for the given git URL, creating its cache repository if needed
+func NewRemoteSource(location string, opts provider.Options) (provider.SourceProvider, error) {
+	if location == "" {
+		return nil, fmt.Errorf("missing git URL")
+	}
+
+	cacheRoot, err := os.UserCacheDir()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find cache directory: %w", err)
+	}
+	sum := sha256.Sum256([]byte(location))
+	cache := filepath.Join(cacheRoot, "templatamus", "git", hex.EncodeToString(sum[:8])+".git")
+
+	if _, err := os.Stat(filepath.Join(cache, "HEAD")); os.IsNotExist(err) {
+		if err := os.MkdirAll(cache, 0755); err != nil {
+			return nil, fmt.Errorf("failed to create cache repository: %w", err)
+		}
+		if _, err := runGit(cache, "init", "--bare", "--quiet"); err != nil {
+			return nil, fmt.Errorf("failed to create cache repository: %w", err)
+		}
+	}
+
+	return &RemoteSource{URL: location, Cache: cache}, nil
+}
+
+// ListTags returns the tags of the remote, newest version first. Tags that
+// aren't version numbers come last, in descending order.
+func (s *RemoteSource) ListTags() ([]string, error) {
+	tags, err := s.refNames("refs/tags/")
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(tags, func(a, b string) int {
+		return version.CompareTags(b, a)
+	})
+	return tags, nil
+}
+
+// ListBranches returns the branches of the remote
+func (s *RemoteSource) ListBranches() ([]string, error) {
+	return s.refNames("refs/heads/")
+}
+
+// DefaultBranch returns the branch the remote's HEAD points to
+func (s *RemoteSource) DefaultBranch() (string, error) {
+	out, err := runGit(s.Cache, "ls-remote", "--symref", s.URL, "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get default branch: %w", err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		// The symref line looks like "ref: refs/heads/main<TAB>HEAD"
+		if target, ok := strings.CutPrefix(scanner.Text(), "ref: refs/heads/"); ok {
+			branch, _, _ := strings.Cut(target, "\t")
+			return branch, nil
+		}
+	}
+	return "", fmt.Errorf("remote %s has no default branch", s.URL)
+}
+
+// ResolveRef returns the commit SHA a branch, tag or SHA points to
+func (s *RemoteSource) ResolveRef(ref string) (string, error) {
+	return s.ensureCommit(ref)
+}
+
+// DownloadArchive exports the tree at ref from the cache as a zip archive
+func (s *RemoteSource) DownloadArchive(ref string) ([]byte, error) {
+	sha, err := s.ensureCommit(ref)
+	if err != nil {
+		return nil, err
+	}
+	return archive(s.Cache, sha)
+}
+
+// ListCommits returns the commits reachable from head but not from base, oldest
+// first. The cache is deepened until base is part of head's fetched history.
+func (s *RemoteSource) ListCommits(base, head string) (*model.Comparison, error) {
+	baseSHA, err := s.ensureCommit(base)
+	if err != nil {
+		return nil, err
+	}
+	headSHA, err := s.ensureCommit(head)
+	if err != nil {
+		return nil, err
+	}
+	headRef, _, _ := s.lookupRef(head)
+
+	for depth := initialDeepen; !s.isAncestor(baseSHA, headSHA) && s.isShallow(); depth *= 2 {
+		if depth > maxDeepen || headRef == "" {
+			if err := s.fetchAll(); err != nil {
+				return nil, err
+			}
+			break
+		}
+		if err := s.fetch([]string{fmt.Sprintf("--deepen=%d", depth)}, "+"+headRef+":"+headRef); err != nil {
+			return nil, err
+		}
+	}
+
+	return compare(s.Cache, baseSHA, headSHA)
+}
+
+// GetCommit returns a single commit
+func (s *RemoteSource) GetCommit(sha string) (*model.CommitInfo, error) {
+	resolved, err := s.ensureCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+	return getCommit(s.Cache, resolved)
+}
+
+// GetDiff returns the changes a commit made as a git diff
+func (s *RemoteSource) GetDiff(sha string) ([]byte, error) {
+	resolved, err := s.ensureCommit(sha)
+	if err != nil {
+		return nil, err
+	}
+
+	// A commit at the shallow boundary looks like it has no parents, which would
+	// turn its diff into the whole tree, so fetch its history first
+	if s.isShallowCommit(resolved) {
+		if err := s.fetchAll(); err != nil {
+			return nil, err
+		}
+	}
+	return commitDiff(s.Cache, resolved)
+}
+
+// APIURL returns "" as plain git remotes have no API
+func (s *RemoteSource) APIURL() string {
+	return ""
+}
+
+// remoteRefs lists the remote's refs once, mapping full ref names to the commit
+// they point to. Annotated tags are peeled to their commit.
+func (s *RemoteSource) remoteRefs() (map[string]string, error) {
+	if s.refs != nil {
+		return s.refs, nil
+	}
+
+	out, err := runGit(s.Cache, "ls-remote", s.URL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list refs of %s: %w", s.URL, err)
+	}
+
+	refs := make(map[string]string)
+	peeled := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		sha, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		if tag, ok := strings.CutSuffix(name, "^{}"); ok {
+			peeled[tag] = sha
+			continue
+		}
+		refs[name] = sha
+	}
+	for name, sha := range peeled {
+		refs[name] = sha
+	}
+
+	s.refs = refs
+	return refs, nil
+}
+
+// refNames returns the short names of the remote refs with the given prefix, in descending order
+func (s *RemoteSource) refNames(prefix string) ([]string, error) {
+	refs, err := s.remoteRefs()
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range refs {
+		if short, ok := strings.CutPrefix(name, prefix); ok {
+			names = append(names, short)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	return names, nil
+}
+
+// lookupRef finds a branch or tag on the remote, preferring branches like git does
+func (s *RemoteSource) lookupRef(ref string) (string, string, bool) {
+	refs, err := s.remoteRefs()
+	if err != nil {
+		return "", "", false
+	}
+	for _, name := range []string{ref, "refs/heads/" + ref, "refs/tags/" + ref} {
+		if sha, ok := refs[name]; ok && strings.HasPrefix(name, "refs/") {
+			return name, sha, true
+		}
+	}
+	return "", "", false
+}
+
+// ensureCommit makes sure the commit ref points to is in the cache and returns its SHA.
+// Branches and tags are fetched shallowly; anything else is treated as a commit SHA.
+func (s *RemoteSource) ensureCommit(ref string) (string, error) {
+	if name, sha, ok := s.lookupRef(ref); ok {
+		if !s.hasCommit(sha) {
+			if err := s.fetch([]string{"--depth=1"}, "+"+name+":"+name); err != nil {
+				return "", err
+			}
+		}
+		return resolveRef(s.Cache, sha)
+	}
+
+	if s.hasCommit(ref) {
+		return resolveRef(s.Cache, ref)
+	}
+
+	// Servers that allow it can send a single commit by SHA, otherwise fetch everything
+	if err := s.fetch([]string{"--depth=1"}, ref); err != nil || !s.hasCommit(ref) {
+		if err := s.fetchAll(); err != nil {
+			return "", err
+		}
+	}
+	return resolveRef(s.Cache, ref)
+}
+
+// fetch runs git fetch with the given options and refspecs from the remote into the cache
+func (s *RemoteSource) fetch(options []string, refspecs ...string) error {
+	args := append([]string{"fetch", "--quiet", "--no-tags", "--update-head-ok"}, options...)
+	args = append(args, s.URL)
+	args = append(args, refspecs...)
+	if _, err := runGit(s.Cache, args...); err != nil {
+		return fmt.Errorf("failed to fetch from %s: %w", s.URL, err)
+	}
+	return nil
+}
+
+// fetchAll fetches the full history of every branch and tag
+func (s *RemoteSource) fetchAll() error {
+	var options []string
+	if s.isShallow() {
+		options = append(options, "--unshallow")
+	}
+	return s.fetch(options, "+refs/heads/*:refs/heads/*", "+refs/tags/*:refs/tags/*")
+}
+
+// hasCommit reports whether ref resolves to a commit in the cache
+func (s *RemoteSource) hasCommit(ref string) bool {
+	_, err := runGit(s.Cache, "cat-file", "-e", ref+"^{commit}")
+	return err == nil
+}
+
+// isAncestor reports whether ancestor is reachable from commit in the cache
+func (s *RemoteSource) isAncestor(ancestor, commit string) bool {
+	_, err := runGit(s.Cache, "merge-base", "--is-ancestor", ancestor, commit)
+	return err == nil
+}
+
+// isShallow reports whether the cache has a truncated history
+func (s *RemoteSource) isShallow() bool {
+	_, err := os.Stat(filepath.Join(s.Cache, "shallow"))
+	return err == nil
+}
+
+// isShallowCommit reports whether sha is at the boundary of the cache's truncated history
+func (s *RemoteSource) isShallowCommit(sha string) bool {
+	data, err := os.ReadFile(filepath.Join(s.Cache, "shallow"))
+	if err != nil {
+		return false
+	}
+	for _, line := range strings.Fields(string(data)) {
+		if line == sha {
+			return true
+		}
+	}
+	return false
+}
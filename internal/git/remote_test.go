@@ -0,0 +1,206 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"templatamus/internal/provider"
+)
+
+// testCommits is the length of the history of the test remote, longer than
+// initialDeepen so ListCommits has to deepen more than once to reach its start
+const testCommits = 40
+
+// testRemote is a bare repository served over file://, whose main branch has
+// testCommits commits each setting counter.txt to their number. v1 is a
+// lightweight tag on the second commit, v2 an annotated tag on the twentieth,
+// and v10 and nightly are lightweight tags on the thirtieth and the last.
+type testRemote struct {
+	URL string
+	// commits holds the SHA of each commit, commits[0] being the first
+	commits []string
+	// tagObject is the SHA of the annotated tag object of v2
+	tagObject string
+}
+
+// newTestRemote creates the test remote. The cache of sources created
+// afterwards lives in a fresh directory, so every test starts from an empty cache.
+func newTestRemote(t *testing.T) *testRemote {
+	t.Helper()
+	root := t.TempDir()
+	t.Setenv("HOME", root)
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(root, "cache"))
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	work := filepath.Join(root, "work")
+	mustGit(t, root, "init", "--quiet", work)
+	mustGit(t, work, "symbolic-ref", "HEAD", "refs/heads/main")
+	if err := os.WriteFile(filepath.Join(work, "README.md"), []byte("# Template\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	remote := &testRemote{}
+	for i := 1; i <= testCommits; i++ {
+		if err := os.WriteFile(filepath.Join(work, "counter.txt"), []byte(fmt.Sprintf("%d\n", i)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		mustGit(t, work, "add", ".")
+		mustGit(t, work, "commit", "--quiet", "-m", fmt.Sprintf("commit %d", i))
+		remote.commits = append(remote.commits, mustGit(t, work, "rev-parse", "HEAD"))
+
+		switch i {
+		case 2:
+			mustGit(t, work, "tag", "v1")
+		case 20:
+			mustGit(t, work, "tag", "-a", "-m", "release 2", "v2")
+		case 30:
+			mustGit(t, work, "tag", "v10")
+		case testCommits:
+			mustGit(t, work, "tag", "nightly")
+		}
+	}
+	remote.tagObject = mustGit(t, work, "rev-parse", "v2")
+
+	bare := filepath.Join(root, "remote.git")
+	mustGit(t, root, "clone", "--quiet", "--bare", work, bare)
+	remote.URL = "file://" + filepath.ToSlash(bare)
+	return remote
+}
+
+// source creates a RemoteSource for the test remote
+func (r *testRemote) source(t *testing.T) *RemoteSource {
+	t.Helper()
+	src, err := NewRemoteSource(r.URL, provider.Options{})
+	if err != nil {
+		t.Fatalf("NewRemoteSource: %v", err)
+	}
+	return src.(*RemoteSource)
+}
+
+// mustGit runs git in dir and returns its trimmed output, failing the test on errors
+func mustGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	out, err := runGit(dir, args...)
+	if err != nil {
+		t.Fatalf("git %s: %v", strings.Join(args, " "), err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestRemoteEnsureCommit(t *testing.T) {
+	remote := newTestRemote(t)
+
+	tests := []struct {
+		name string
+		ref  string
+		want string
+	}{
+		{name: "branch", ref: "main", want: remote.commits[testCommits-1]},
+		{name: "lightweight tag", ref: "v1", want: remote.commits[1]},
+		{name: "annotated tag", ref: "v2", want: remote.commits[19]},
+		{name: "raw SHA", ref: remote.commits[9], want: remote.commits[9]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := remote.source(t)
+			got, err := src.ensureCommit(tt.ref)
+			if err != nil {
+				t.Fatalf("ensureCommit(%s): %v", tt.ref, err)
+			}
+			if got != tt.want {
+				t.Errorf("ensureCommit(%s) = %s, want %s", tt.ref, got, tt.want)
+			}
+			if !src.hasCommit(tt.want) {
+				t.Errorf("commit %s is not in the cache", tt.want)
+			}
+		})
+	}
+}
+
+func TestRemoteRefsPeelAnnotatedTags(t *testing.T) {
+	remote := newTestRemote(t)
+	src := remote.source(t)
+
+	refs, err := src.remoteRefs()
+	if err != nil {
+		t.Fatalf("remoteRefs: %v", err)
+	}
+	if got := refs["refs/tags/v2"]; got != remote.commits[19] {
+		t.Errorf("refs/tags/v2 = %s, want the tagged commit %s (tag object %s)", got, remote.commits[19], remote.tagObject)
+	}
+	if got := refs["refs/tags/v1"]; got != remote.commits[1] {
+		t.Errorf("refs/tags/v1 = %s, want %s", got, remote.commits[1])
+	}
+	if _, ok := refs["refs/tags/v2^{}"]; ok {
+		t.Errorf("peeled entry refs/tags/v2^{} kept as a ref")
+	}
+
+	tags, err := src.ListTags()
+	if err != nil {
+		t.Fatalf("ListTags: %v", err)
+	}
+	// Versions sort by number, not by name, and other tags come last
+	if got := strings.Join(tags, ","); got != "v10,v2,v1,nightly" {
+		t.Errorf("ListTags = %s, want v10,v2,v1,nightly", got)
+	}
+}
+
+func TestRemoteListCommitsDeepens(t *testing.T) {
+	remote := newTestRemote(t)
+	src := remote.source(t)
+
+	comparison, err := src.ListCommits("v1", "main")
+	if err != nil {
+		t.Fatalf("ListCommits: %v", err)
+	}
+
+	want := remote.commits[2:]
+	if comparison.AheadBy != len(want) || comparison.BehindBy != 0 {
+		t.Errorf("ahead %d, behind %d; want ahead %d, behind 0", comparison.AheadBy, comparison.BehindBy, len(want))
+	}
+	if len(comparison.Commits) != len(want) {
+		t.Fatalf("got %d commits, want %d", len(comparison.Commits), len(want))
+	}
+	for i, commit := range comparison.Commits {
+		if commit.SHA != want[i] {
+			t.Fatalf("commit %d = %s, want %s", i, commit.SHA, want[i])
+		}
+	}
+	if !src.isAncestor(remote.commits[1], remote.commits[testCommits-1]) {
+		t.Errorf("v1 is not reachable from main in the cache")
+	}
+}
+
+func TestRemoteGetDiffAtShallowBoundary(t *testing.T) {
+	remote := newTestRemote(t)
+	src := remote.source(t)
+
+	// Fetching the tag alone leaves its commit at the shallow boundary
+	sha, err := src.ResolveRef("v2")
+	if err != nil {
+		t.Fatalf("ResolveRef: %v", err)
+	}
+	if !src.isShallowCommit(sha) {
+		t.Fatalf("expected %s at the shallow boundary of the cache", sha)
+	}
+
+	diff, err := src.GetDiff(sha)
+	if err != nil {
+		t.Fatalf("GetDiff: %v", err)
+	}
+	patch := string(diff)
+	if strings.Contains(patch, "README.md") || strings.Contains(patch, "new file mode") {
+		t.Errorf("GetDiff returned the whole tree:\n%s", patch)
+	}
+	if !strings.Contains(patch, "-19\n+20\n") {
+		t.Errorf("GetDiff is missing the change of commit 20:\n%s", patch)
+	}
+}
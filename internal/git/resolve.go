@@ -0,0 +1,196 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// conflictMarkers are the line prefixes git writes around conflicting hunks. The
+// "=======" separator is left out as it is also a common heading underline.
+var conflictMarkers = []string{"<<<<<<< ", ">>>>>>> "}
+
+// PatchFiles returns the paths a patch touches
+func PatchFiles(dir string, patch []byte) ([]string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", "apply", "--numstat", "-z", "-")
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(patch)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	// Each entry is "added<TAB>deleted<TAB>path" terminated by NUL
+	var paths []string
+	for _, entry := range strings.Split(string(out), "\x00") {
+		fields := strings.SplitN(entry, "\t", 3)
+		if len(fields) == 3 && fields[2] != "" {
+			paths = append(paths, fields[2])
+		}
+	}
+	return paths, nil
+}
+
+// HasConflictMarkers reports whether the file at path still contains conflict markers
+func HasConflictMarkers(dir, path string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		for _, marker := range conflictMarkers {
+			if strings.HasPrefix(scanner.Text(), marker) {
+				return true, nil
+			}
+		}
+	}
+	return false, scanner.Err()
+}
+
+// IsUnmerged reports whether path has unmerged entries in the index
+func IsUnmerged(dir, path string) (bool, error) {
+	out, err := runGit(dir, "ls-files", "--unmerged", "--", path)
+	if err != nil {
+		return false, fmt.Errorf("failed to check for conflicts in %s: %w", path, err)
+	}
+	return len(out) > 0, nil
+}
+
+// HasReject reports whether path has a .rej file next to it
+func HasReject(dir, path string) bool {
+	_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path)+".rej"))
+	return err == nil
+}
+
+// RemoveReject deletes the .rej file next to path, if there is one
+func RemoveReject(dir, path string) error {
+	err := os.Remove(filepath.Join(dir, filepath.FromSlash(path)+".rej"))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove .rej file for %s: %w", path, err)
+	}
+	return nil
+}
+
+// CheckoutFrom replaces path in the index and working tree with its version in
+// treeish, deleting it if treeish doesn't contain it, and drops its .rej file
+func CheckoutFrom(dir, treeish, path string) error {
+	out, err := runGit(dir, "ls-tree", "--name-only", treeish, "--", path)
+	if err != nil {
+		return fmt.Errorf("failed to look up %s: %w", path, err)
+	}
+	if len(out) > 0 {
+		_, err = runGit(dir, "checkout", treeish, "--", path)
+	} else {
+		_, err = runGit(dir, "rm", "--force", "--quiet", "--ignore-unmatch", "--", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check out %s from %s: %w", path, treeish, err)
+	}
+	return RemoveReject(dir, path)
+}
+
+// SkipConflictingHunks resolves every conflicting hunk of an unmerged file in
+// favour of the project's side, keeping the upstream changes that merged cleanly
+func SkipConflictingHunks(dir, path string) error {
+	tempDir, err := os.MkdirTemp("", "templatamus-merge-")
+	if err != nil {
+		return fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	mode, err := conflictMode(dir, path)
+	if err != nil {
+		return err
+	}
+
+	// Stage 1 is the base, 2 the project's version and 3 the upstream version.
+	// Files added on both sides have no base, which merges like an empty file.
+	stages := make([]string, 3)
+	for i := range stages {
+		stages[i] = filepath.Join(tempDir, fmt.Sprintf("stage%d", i+1))
+		content, err := runGit(dir, "show", fmt.Sprintf(":%d:%s", i+1, path))
+		if err != nil {
+			content = nil
+		}
+		if err := os.WriteFile(stages[i], content, mode); err != nil {
+			return fmt.Errorf("failed to write merge input: %w", err)
+		}
+	}
+
+	// merge-file exits with the number of conflicts, which --ours makes zero
+	merged, err := runGit(dir, "merge-file", "--ours", "-p", stages[1], stages[0], stages[2])
+	if err != nil {
+		return fmt.Errorf("failed to merge %s: %w", path, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(path)), merged, mode); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return Stage(dir, path)
+}
+
+// conflictMode returns the permissions of an unmerged file: those of the file
+// in the working tree, or if it was deleted there, those the project's or
+// else the upstream version has in the index
+func conflictMode(dir, path string) (os.FileMode, error) {
+	info, err := os.Stat(filepath.Join(dir, filepath.FromSlash(path)))
+	if err == nil {
+		return info.Mode().Perm(), nil
+	}
+	if !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	out, err := runGit(dir, "ls-files", "--stage", "--", path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to look up %s: %w", path, err)
+	}
+	// Entries read "<mode> <sha> <stage>\t<path>", ordered by stage
+	modes := make(map[string]string)
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if fields := strings.Fields(line); len(fields) >= 3 {
+			modes[fields[2]] = fields[0]
+		}
+	}
+	for _, stage := range []string{"2", "3"} {
+		if mode, ok := modes[stage]; ok {
+			if mode == "100755" {
+				return 0755, nil
+			}
+			return 0644, nil
+		}
+	}
+	return 0644, nil
+}
+
+// Stage adds path to the index, marking it resolved
+func Stage(dir, path string) error {
+	if _, err := runGit(dir, "add", "--all", "--", path); err != nil {
+		return fmt.Errorf("failed to stage %s: %w", path, err)
+	}
+	return nil
+}
+
+// MergeTool runs git mergetool on a conflicted file
+func MergeTool(dir, path string) error {
+	cmd := exec.Command("git", "mergetool", "--", path)
+	cmd.Dir = dir
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git mergetool failed: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package git
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSkipConflictingHunksKeepsMode(t *testing.T) {
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("GIT_AUTHOR_NAME", "Test")
+	t.Setenv("GIT_AUTHOR_EMAIL", "test@example.com")
+	t.Setenv("GIT_COMMITTER_NAME", "Test")
+	t.Setenv("GIT_COMMITTER_EMAIL", "test@example.com")
+
+	for _, removed := range []bool{false, true} {
+		name := "in working tree"
+		if removed {
+			name = "removed from working tree"
+		}
+		t.Run(name, func(t *testing.T) {
+			dir := t.TempDir()
+			script := filepath.Join(dir, "run.sh")
+			write := func(content string) {
+				t.Helper()
+				if err := os.WriteFile(script, []byte(content), 0755); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mustGit(t, dir, "init", "--quiet", "--initial-branch=main")
+			write("#!/bin/sh\necho base\n")
+			mustGit(t, dir, "add", ".")
+			mustGit(t, dir, "commit", "--quiet", "-m", "base")
+			mustGit(t, dir, "checkout", "--quiet", "-b", "upstream")
+			write("#!/bin/sh\necho upstream\n")
+			mustGit(t, dir, "commit", "--quiet", "-am", "upstream")
+			mustGit(t, dir, "checkout", "--quiet", "main")
+			write("#!/bin/sh\necho ours\n")
+			mustGit(t, dir, "commit", "--quiet", "-am", "ours")
+			if _, err := runGit(dir, "merge", "upstream"); err == nil {
+				t.Fatal("expected the merge to conflict")
+			}
+			if removed {
+				if err := os.Remove(script); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := SkipConflictingHunks(dir, "run.sh"); err != nil {
+				t.Fatalf("SkipConflictingHunks: %v", err)
+			}
+
+			data, err := os.ReadFile(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != "#!/bin/sh\necho ours\n" {
+				t.Errorf("run.sh = %q, want the project's version", data)
+			}
+			info, err := os.Stat(script)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm()&0100 == 0 {
+				t.Errorf("run.sh has mode %v, want it executable", info.Mode())
+			}
+			if got := mustGit(t, dir, "ls-files", "--stage", "--", "run.sh"); got[:6] != "100755" {
+				t.Errorf("index entry %q, want mode 100755", got)
+			}
+		})
+	}
+}
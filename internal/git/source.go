@@ -0,0 +1,268 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"templatamus/internal/model"
+	"templatamus/internal/paths"
+	"templatamus/internal/provider"
+)
+
+func init() {
+	provider.Register("file", NewLocalSource)
+}
+
+// commitFormat is the git log format parseCommits understands: fields are
+// separated by NUL and commits by the record separator
+const commitFormat = "%H%x00%P%x00%an%x00%aI%x00%B%x1e"
+
+// LocalSource is a provider.SourceProvider backed by a git repository on disk,
+// so templates can be tried out before they are pushed anywhere
+type LocalSource struct {
+	Dir string
+}
+
+// NewLocalSource creates a LocalSource for the repository at the given path
+func NewLocalSource(location string, opts provider.Options) (provider.SourceProvider, error) {
+	dir, err := paths.Expand(location)
+	if err != nil {
+		return nil, err
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("invalid repository path %q: %w", location, err)
+	}
+
+	if _, err := runGit(dir, "rev-parse", "--git-dir"); err != nil {
+		return nil, fmt.Errorf("%s is not a git repository: %w", dir, err)
+	}
+	return &LocalSource{Dir: dir}, nil
+}
+
+// ListTags returns the tags of the repository, newest first
+func (s *LocalSource) ListTags() ([]string, error) {
+	return listRefs(s.Dir, "--sort=-creatordate", "refs/tags")
+}
+
+// ListBranches returns the local branches of the repository
+func (s *LocalSource) ListBranches() ([]string, error) {
+	return listRefs(s.Dir, "refs/heads")
+}
+
+// DefaultBranch returns the branch HEAD points to
+func (s *LocalSource) DefaultBranch() (string, error) {
+	out, err := runGit(s.Dir, "symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", fmt.Errorf("failed to get default branch: %w", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// ResolveRef returns the commit SHA a branch, tag or SHA points to
+func (s *LocalSource) ResolveRef(ref string) (string, error) {
+	return resolveRef(s.Dir, ref)
+}
+
+// DownloadArchive exports the tree at ref as a zip archive with a single root directory
+func (s *LocalSource) DownloadArchive(ref string) ([]byte, error) {
+	return archive(s.Dir, ref)
+}
+
+// ListCommits returns the commits reachable from head but not from base, oldest first
+func (s *LocalSource) ListCommits(base, head string) (*model.Comparison, error) {
+	return compare(s.Dir, base, head)
+}
+
+// GetCommit returns a single commit
+func (s *LocalSource) GetCommit(sha string) (*model.CommitInfo, error) {
+	return getCommit(s.Dir, sha)
+}
+
+// GetDiff returns the changes a commit made as a git diff
+func (s *LocalSource) GetDiff(sha string) ([]byte, error) {
+	return commitDiff(s.Dir, sha)
+}
+
+// APIURL returns "" as local repositories have no API
+func (s *LocalSource) APIURL() string {
+	return ""
+}
+
+// runGit runs git in dir and returns its output, including stderr in the error
+func runGit(dir string, args ...string) ([]byte, error) {
+	return runGitEnv(dir, nil, args...)
+}
+
+// runGitEnv is runGit with extra environment variables
+func runGitEnv(dir string, env []string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), env...)
+	}
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return nil, fmt.Errorf("git %s: %w", args[0], err)
+		}
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+	}
+	return out, nil
+}
+
+// listRefs returns the short names of the refs below the given prefix
+func listRefs(dir string, args ...string) ([]string, error) {
+	args = append([]string{"for-each-ref", "--format=%(refname:short)"}, args...)
+	out, err := runGit(dir, args...)
+	if err != nil {
+		return nil, err
+	}
+	return strings.Fields(string(out)), nil
+}
+
+// resolveRef returns the commit SHA ref points to, wrapping provider.ErrNotFound if it doesn't exist
+func resolveRef(dir, ref string) (string, error) {
+	out, err := runGit(dir, "rev-parse", "--verify", "--quiet", ref+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("ref %s: %w", ref, provider.ErrNotFound)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// archive exports the tree at ref as a zip archive whose root directory is
+// named after the commit, like the archives hosting services produce
+func archive(dir, ref string) ([]byte, error) {
+	sha, err := resolveRef(dir, ref)
+	if err != nil {
+		return nil, err
+	}
+	prefix := fmt.Sprintf("%s-%s/", filepath.Base(dir), sha[:8])
+	out, err := runGit(dir, "archive", "--format=zip", "--prefix="+prefix, sha)
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive %s: %w", ref, err)
+	}
+	return out, nil
+}
+
+// compare lists the commits in head that aren't in base, oldest first, and how the two relate
+func compare(dir, base, head string) (*model.Comparison, error) {
+	baseSHA, err := resolveRef(dir, base)
+	if err != nil {
+		return nil, err
+	}
+	headSHA, err := resolveRef(dir, head)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := runGit(dir, "rev-list", "--left-right", "--count", baseSHA+"..."+headSHA)
+	if err != nil {
+		return nil, err
+	}
+	counts := strings.Fields(string(out))
+	if len(counts) != 2 {
+		return nil, fmt.Errorf("unexpected rev-list output %q", out)
+	}
+	behindBy, _ := strconv.Atoi(counts[0])
+	aheadBy, _ := strconv.Atoi(counts[1])
+
+	out, err = runGit(dir, "log", "--reverse", "--topo-order", "--format="+commitFormat, baseSHA+".."+headSHA)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseCommits(out)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &model.Comparison{AheadBy: aheadBy, BehindBy: behindBy, Commits: commits}
+	switch {
+	case aheadBy == 0 && behindBy == 0:
+		comparison.Status = "identical"
+	case behindBy == 0:
+		comparison.Status = "ahead"
+	case aheadBy == 0:
+		comparison.Status = "behind"
+	default:
+		comparison.Status = "diverged"
+	}
+	return comparison, nil
+}
+
+// getCommit returns a single commit
+func getCommit(dir, sha string) (*model.CommitInfo, error) {
+	resolved, err := resolveRef(dir, sha)
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(dir, "log", "-1", "--format="+commitFormat, resolved)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := parseCommits(out)
+	if err != nil {
+		return nil, err
+	}
+	if len(commits) != 1 {
+		return nil, fmt.Errorf("commit %s: %w", sha, provider.ErrNotFound)
+	}
+	return &commits[0], nil
+}
+
+// commitDiff returns the changes a commit made relative to its first parent
+func commitDiff(dir, sha string) ([]byte, error) {
+	resolved, err := resolveRef(dir, sha)
+	if err != nil {
+		return nil, err
+	}
+	out, err := runGit(dir, "diff-tree", "-p", "--binary", "--full-index", "--root", "-m", "--first-parent", resolved)
+	if err != nil {
+		return nil, err
+	}
+
+	// diff-tree prints the commit SHA before the patch
+	if line, rest, ok := bytes.Cut(out, []byte("\n")); ok && string(line) == resolved {
+		out = rest
+	}
+	return out, nil
+}
+
+// parseCommits parses git log output written with commitFormat
+func parseCommits(out []byte) ([]model.CommitInfo, error) {
+	var commits []model.CommitInfo
+	for _, record := range strings.Split(string(out), "\x1e") {
+		record = strings.TrimLeft(record, "\n")
+		if record == "" {
+			continue
+		}
+
+		fields := strings.SplitN(record, "\x00", 5)
+		if len(fields) != 5 {
+			return nil, errors.New("unexpected git log output")
+		}
+		date, err := time.Parse(time.RFC3339, fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse commit date: %w", err)
+		}
+
+		commits = append(commits, model.CommitInfo{
+			SHA:     fields[0],
+			Parents: strings.Fields(fields[1]),
+			Author:  fields[2],
+			Date:    date,
+			Message: strings.TrimSpace(fields[4]),
+		})
+	}
+	return commits, nil
+}
@@ -0,0 +1,90 @@
+package git
+
+import (
+	"fmt"
+	"strings"
+)
+
+// UpstreamRef records the rendered template tree a project was created or last
+// synced from, so git diff UpstreamRef HEAD shows how the project diverged
+const UpstreamRef = "refs/templatamus/upstream"
+
+// upstreamTrailer is the commit message trailer holding the template commit SHA
+const upstreamTrailer = "Template-Commit"
+
+// upstreamIdentity is used for the commits on UpstreamRef, which aren't authored by the user
+var upstreamIdentity = []string{
+	"GIT_AUTHOR_NAME=Templatamus",
+	"GIT_AUTHOR_EMAIL=templatamus@localhost",
+	"GIT_COMMITTER_NAME=Templatamus",
+	"GIT_COMMITTER_EMAIL=templatamus@localhost",
+}
+
+// IsRepo reports whether dir is inside a git work tree
+func IsRepo(dir string) bool {
+	out, err := runGit(dir, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(string(out)) == "true"
+}
+
+// RecordUpstream commits tree to UpstreamRef as the template at the given
+// commit SHA, on top of what the ref recorded before
+func RecordUpstream(dir, tree, sha, message string) error {
+	args := []string{"commit-tree", tree, "-m", message, "-m", upstreamTrailer + ": " + sha}
+	parent, err := runGit(dir, "rev-parse", "--verify", "--quiet", UpstreamRef+"^{commit}")
+	if err == nil {
+		args = append(args, "-p", strings.TrimSpace(string(parent)))
+	}
+
+	out, err := runGitEnv(dir, upstreamIdentity, args...)
+	if err != nil {
+		return fmt.Errorf("failed to commit template tree: %w", err)
+	}
+	commit := strings.TrimSpace(string(out))
+
+	if _, err := runGit(dir, "update-ref", "-m", message, UpstreamRef, commit); err != nil {
+		return fmt.Errorf("failed to update %s: %w", UpstreamRef, err)
+	}
+	return nil
+}
+
+// UpstreamRefCommit returns the commit UpstreamRef points to, or "" if it doesn't exist
+func UpstreamRefCommit(dir string) (string, error) {
+	out, err := runGit(dir, "for-each-ref", "--format=%(objectname)", UpstreamRef)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", UpstreamRef, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// RestoreUpstreamRef points UpstreamRef back at commit, deleting it if commit is ""
+func RestoreUpstreamRef(dir, commit string) error {
+	var err error
+	if commit == "" {
+		_, err = runGit(dir, "update-ref", "-d", UpstreamRef)
+	} else {
+		_, err = runGit(dir, "update-ref", "-m", "restore", UpstreamRef, commit)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore %s: %w", UpstreamRef, err)
+	}
+	return nil
+}
+
+// Upstream returns the template commit SHA and tree recorded in UpstreamRef,
+// or empty strings if the ref doesn't exist
+func Upstream(dir string) (string, string, error) {
+	if _, err := runGit(dir, "rev-parse", "--verify", "--quiet", UpstreamRef); err != nil {
+		return "", "", nil
+	}
+
+	format := "--format=%(trailers:key=" + upstreamTrailer + ",valueonly)%x00%T"
+	out, err := runGit(dir, "log", "-1", format, UpstreamRef)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read %s: %w", UpstreamRef, err)
+	}
+	sha, tree, ok := strings.Cut(string(out), "\x00")
+	if !ok {
+		return "", "", fmt.Errorf("unexpected output reading %s: %q", UpstreamRef, out)
+	}
+	return strings.TrimSpace(sha), strings.TrimSpace(tree), nil
+}
@@ -4,101 +4,94 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
+	"strings"
 	"time"
 
 	"templatamus/internal/model"
+	"templatamus/internal/provider"
 )
 
+// perPage is the page size requested from list endpoints, the maximum GitHub allows
+const perPage = 100
+
+// DefaultAPIURL is the API of github.com, used when no other server is configured
+const DefaultAPIURL = "https://api.github.com"
+
 // Client represents a GitHub API client
 type Client struct {
 	Token string
+	// BaseURL is the API root, e.g. https://github.example.com/api/v3 for GitHub Enterprise Server
+	BaseURL string
+	// DownloadURL is the root archives are downloaded from, the API root unless configured otherwise
+	DownloadURL string
+
+	transport      *provider.Transport
+	rateLimit      *RateLimit
+	warnedLowQuota bool
 }
 
-// NewClient creates a new GitHub client
-func NewClient(token string) *Client {
-	return &Client{Token: token}
-}
-
-// GetTags retrieves all tags for a repository
-func (c *Client) GetTags(owner, repo string) ([]string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/tags", owner, repo)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
-
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return nil, err
+// NewClient creates a new GitHub client for the given server
+func NewClient(token string, server model.ServerConfig) *Client {
+	baseURL := strings.TrimSuffix(server.APIURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
-	var tags []struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
-		return nil, err
+	downloadURL := strings.TrimSuffix(server.DownloadURL, "/")
+	if downloadURL == "" {
+		downloadURL = baseURL
 	}
+	c := &Client{Token: token, BaseURL: baseURL, DownloadURL: downloadURL}
+	c.transport = c.newTransport()
+	return c
+}
 
-	result := []string{}
-	for _, tag := range tags {
-		result = append(result, tag.Name)
-	}
-	return result, nil
+// GetTags retrieves the tags for a repository, following pagination.
+// A limit of 0 or less retrieves all of them.
+func (c *Client) GetTags(owner, repo string, limit int) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=%d", c.BaseURL, owner, repo, perPage)
+	return c.getNames(url, limit)
 }
 
-// GetBranches retrieves all branches for a repository
-func (c *Client) GetBranches(owner, repo string) ([]string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/branches", owner, repo)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
+// GetBranches retrieves the branches for a repository, following pagination.
+// A limit of 0 or less retrieves all of them.
+func (c *Client) GetBranches(owner, repo string, limit int) ([]string, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/branches?per_page=%d", c.BaseURL, owner, repo, perPage)
+	return c.getNames(url, limit)
+}
 
-	resp, err := http.DefaultClient.Do(req)
+// getNames collects the name field of every item in a paginated list
+func (c *Client) getNames(url string, limit int) ([]string, error) {
+	result := []string{}
+	err := c.getPaged(url, func(body io.Reader) (bool, error) {
+		var items []struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(body).Decode(&items); err != nil {
+			return false, err
+		}
+		for _, item := range items {
+			result = append(result, item.Name)
+			if limit > 0 && len(result) >= limit {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
-	var branches []struct {
-		Name string `json:"name"`
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&branches); err != nil {
-		return nil, err
-	}
-
-	result := []string{}
-	for _, b := range branches {
-		result = append(result, b.Name)
-	}
 	return result, nil
 }
 
 // GetDefaultBranch retrieves the default branch for a repository
 func (c *Client) GetDefaultBranch(owner, repo string) (string, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s", owner, repo)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
-
-	resp, err := http.DefaultClient.Do(req)
+	url := fmt.Sprintf("%s/repos/%s/%s", c.BaseURL, owner, repo)
+	resp, err := c.get(url, "")
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
 	var data struct {
 		DefaultBranch string `json:"default_branch"`
 	}
@@ -110,163 +103,195 @@ func (c *Client) GetDefaultBranch(owner, repo string) (string, error) {
 
 // DownloadZip downloads a repository as a zip archive
 func (c *Client) DownloadZip(owner, repo, ref string) ([]byte, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/zipball/%s", owner, repo, ref)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
-
-	resp, err := http.DefaultClient.Do(req)
+	url := fmt.Sprintf("%s/repos/%s/%s/zipball/%s", c.DownloadURL, owner, repo, ref)
+	resp, err := c.get(url, "")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
 	return io.ReadAll(resp.Body)
 }
 
-// GetCommits retrieves commits for a repository
-func (c *Client) GetCommits(owner, repo, branch string, since time.Time) ([]model.CommitInfo, error) {
-	// We'll use per_page=100 to get more commits in one response
-	// NOTE: This is limited to the first 100 commits, which should be enough for most cases
-	// For repositories with more commits, we'd need to implement pagination
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits?sha=%s&per_page=100", owner, repo, branch)
-	
+// GetCommits retrieves commits for a branch, newest first, following pagination.
+// A limit of 0 or less retrieves all of them.
+func (c *Client) GetCommits(owner, repo, branch string, since time.Time, limit int) ([]model.CommitInfo, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/commits?sha=%s&per_page=%d", c.BaseURL, owner, repo, branch, perPage)
+
 	// Add since parameter if provided and not zero
 	if !since.IsZero() {
 		url += fmt.Sprintf("&since=%s", since.Format(time.RFC3339))
 	}
-	
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
 
-	resp, err := http.DefaultClient.Do(req)
+	commits := []model.CommitInfo{}
+	err := c.getPaged(url, func(body io.Reader) (bool, error) {
+		var ghCommits []ghCommit
+		if err := json.NewDecoder(body).Decode(&ghCommits); err != nil {
+			return false, err
+		}
+		for _, gc := range ghCommits {
+			commits = append(commits, gc.toCommitInfo())
+			if limit > 0 && len(commits) >= limit {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
+	return commits, nil
+}
 
-	var ghCommits []struct {
-		SHA    string `json:"sha"`
-		Commit struct {
-			Message string `json:"message"`
-			Author  struct {
-				Name  string    `json:"name"`
-				Date  time.Time `json:"date"`
-			} `json:"author"`
-		} `json:"commit"`
-		HTMLURL string `json:"html_url"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&ghCommits); err != nil {
+// CompareCommits lists the commits reachable from head but not from base, in
+// topological order (oldest first), following pagination
+func (c *Client) CompareCommits(owner, repo, base, head string) (*model.Comparison, error) {
+	url := fmt.Sprintf("%s/repos/%s/%s/compare/%s...%s?per_page=%d", c.BaseURL, owner, repo, base, head, perPage)
+
+	var comparison *model.Comparison
+	err := c.getPaged(url, func(body io.Reader) (bool, error) {
+		var page struct {
+			Status   string     `json:"status"`
+			AheadBy  int        `json:"ahead_by"`
+			BehindBy int        `json:"behind_by"`
+			Commits  []ghCommit `json:"commits"`
+		}
+		if err := json.NewDecoder(body).Decode(&page); err != nil {
+			return false, err
+		}
+
+		// The status and counts are the same on every page
+		if comparison == nil {
+			comparison = &model.Comparison{
+				Status:   page.Status,
+				AheadBy:  page.AheadBy,
+				BehindBy: page.BehindBy,
+			}
+		}
+		for _, gc := range page.Commits {
+			comparison.Commits = append(comparison.Commits, gc.toCommitInfo())
+		}
+		return false, nil
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	commits := make([]model.CommitInfo, 0, len(ghCommits))
-	for _, c := range ghCommits {
-		commits = append(commits, model.CommitInfo{
-			SHA:     c.SHA,
-			Message: c.Commit.Message,
-			Author:  c.Commit.Author.Name,
-			Date:    c.Commit.Author.Date,
-			URL:     c.HTMLURL,
-		})
-	}
-
-	return commits, nil
+	return comparison, nil
 }
 
 // GetCommit retrieves a single commit
 func (c *Client) GetCommit(owner, repo, sha string) (*model.CommitInfo, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", owner, repo, sha)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
-
-	resp, err := http.DefaultClient.Do(req)
+	url := fmt.Sprintf("%s/repos/%s/%s/commits/%s", c.BaseURL, owner, repo, sha)
+	resp, err := c.get(url, "")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
-	var ghCommit struct {
-		SHA    string `json:"sha"`
-		Commit struct {
-			Message string `json:"message"`
-			Author  struct {
-				Name  string    `json:"name"`
-				Date  time.Time `json:"date"`
-			} `json:"author"`
-		} `json:"commit"`
-		HTMLURL string `json:"html_url"`
-	}
-	
-	if err := json.NewDecoder(resp.Body).Decode(&ghCommit); err != nil {
+	var commit ghCommit
+	if err := json.NewDecoder(resp.Body).Decode(&commit); err != nil {
 		return nil, err
 	}
 
-	return &model.CommitInfo{
-		SHA:     ghCommit.SHA,
-		Message: ghCommit.Commit.Message,
-		Author:  ghCommit.Commit.Author.Name,
-		Date:    ghCommit.Commit.Author.Date,
-		URL:     ghCommit.HTMLURL,
-	}, nil
+	info := commit.toCommitInfo()
+	return &info, nil
 }
 
 // GetDiff gets the diff for a commit
 func (c *Client) GetDiff(owner, repo, sha string) ([]byte, error) {
-	url := fmt.Sprintf("https://api.github.com/repos/%s/%s/commits/%s", owner, repo, sha)
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Set("Authorization", "token "+c.Token)
-	req.Header.Set("Accept", "application/vnd.github.diff")
-
-	resp, err := http.DefaultClient.Do(req)
+	url := fmt.Sprintf("%s/repos/%s/%s/commits/%s", c.BaseURL, owner, repo, sha)
+	resp, err := c.get(url, "application/vnd.github.diff")
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
-	}
-
 	return io.ReadAll(resp.Body)
 }
 
 // GetJSON performs a GET request to the GitHub API and unmarshals the response JSON into the provided object
 func (c *Client) GetJSON(url string, v interface{}) error {
-	req, err := http.NewRequest("GET", url, nil)
+	resp, err := c.get(url, "application/vnd.github.v3+json")
 	if err != nil {
 		return err
 	}
-	
-	req.Header.Set("Authorization", "token "+c.Token)
-	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	defer resp.Body.Close()
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// ghCommit is a commit as returned by the GitHub commits API
+type ghCommit struct {
+	SHA    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name string    `json:"name"`
+			Date time.Time `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+	HTMLURL string `json:"html_url"`
+	Parents []struct {
+		SHA string `json:"sha"`
+	} `json:"parents"`
+}
+
+// toCommitInfo converts a GitHub commit to our model
+func (gc ghCommit) toCommitInfo() model.CommitInfo {
+	info := model.CommitInfo{
+		SHA:     gc.SHA,
+		Message: gc.Commit.Message,
+		Author:  gc.Commit.Author.Name,
+		Date:    gc.Commit.Author.Date,
+		URL:     gc.HTMLURL,
 	}
-	defer resp.Body.Close()
+	for _, parent := range gc.Parents {
+		info.Parents = append(info.Parents, parent.SHA)
+	}
+	return info
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("GitHub API error: %s: %s", resp.Status, body)
+// getPaged performs GET requests starting at url and following the Link rel="next"
+// header. handle is called with the body of each page and returns true to stop paging.
+func (c *Client) getPaged(url string, handle func(body io.Reader) (bool, error)) error {
+	for url != "" {
+		resp, err := c.get(url, "")
+		if err != nil {
+			return err
+		}
+
+		stop, err := handle(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		if stop {
+			return nil
+		}
+
+		url = nextPageURL(resp.Header.Get("Link"))
 	}
+	return nil
+}
 
-	return json.NewDecoder(resp.Body).Decode(v)
-} 
\ No newline at end of file
+// nextPageURL extracts the rel="next" URL from a Link header, or "" if there is none
+func nextPageURL(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		segments := strings.Split(part, ";")
+		if len(segments) < 2 {
+			continue
+		}
+		target := strings.TrimSpace(segments[0])
+		if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+			continue
+		}
+		for _, param := range segments[1:] {
+			if strings.TrimSpace(param) == `rel="next"` {
+				return target[1 : len(target)-1]
+			}
+		}
+	}
+	return ""
+}
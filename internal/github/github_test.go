@@ -0,0 +1,197 @@
+package github
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"templatamus/internal/model"
+)
+
+// newTestClient starts a stand-in GitHub API serving handler and returns a
+// client talking to it
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient("secret", model.ServerConfig{APIURL: server.URL})
+}
+
+// writeJSON writes v as the JSON body of a 200 response
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("failed to encode response: %v", err)
+	}
+}
+
+// setLink sets the Link header GitHub sends on page of a list with the given
+// number of pages
+func setLink(w http.ResponseWriter, r *http.Request, page, pages int) {
+	link := func(page int, rel string) string {
+		u := *r.URL
+		q := u.Query()
+		q.Set("page", strconv.Itoa(page))
+		u.RawQuery = q.Encode()
+		return fmt.Sprintf(`<http://%s%s>; rel="%s"`, r.Host, u.RequestURI(), rel)
+	}
+	header := link(1, "first")
+	if page < pages {
+		header = link(page+1, "next") + ", " + link(pages, "last")
+	}
+	w.Header().Set("Link", header)
+}
+
+// pageNumber returns the page query parameter of r, 1 if there is none
+func pageNumber(r *http.Request) int {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil {
+		return 1
+	}
+	return page
+}
+
+func TestNextPageURL(t *testing.T) {
+	tests := []struct {
+		name string
+		link string
+		want string
+	}{
+		{name: "no header", link: "", want: ""},
+		{
+			name: "next and last",
+			link: `<https://api.github.com/repositories/1/tags?page=2>; rel="next", <https://api.github.com/repositories/1/tags?page=5>; rel="last"`,
+			want: "https://api.github.com/repositories/1/tags?page=2",
+		},
+		{
+			name: "last page",
+			link: `<https://api.github.com/repositories/1/tags?page=1>; rel="first", <https://api.github.com/repositories/1/tags?page=4>; rel="prev"`,
+			want: "",
+		},
+		{name: "malformed target", link: `https://api.github.com/?page=2; rel="next"`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextPageURL(tt.link); got != tt.want {
+				t.Errorf("nextPageURL = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetTagsFollowsLinkHeader(t *testing.T) {
+	pages := [][]string{{"v1", "v2"}, {"v3"}, {"v4"}}
+	var requested []int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/template/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "token secret" {
+			t.Errorf("Authorization = %q, want %q", got, "token secret")
+		}
+		if got := r.URL.Query().Get("per_page"); got != "100" {
+			t.Errorf("per_page = %q, want 100", got)
+		}
+
+		page := pageNumber(r)
+		requested = append(requested, page)
+		setLink(w, r, page, len(pages))
+		var items []map[string]string
+		for _, name := range pages[page-1] {
+			items = append(items, map[string]string{"name": name})
+		}
+		writeJSON(t, w, items)
+	})
+
+	tags, err := client.GetTags("org", "template", 0)
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if want := []string{"v1", "v2", "v3", "v4"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested pages %v, want %v", requested, want)
+	}
+}
+
+func TestGetCommitsStopsAtLimit(t *testing.T) {
+	const pages = 5
+	var requested []int
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/template/commits" || r.URL.Query().Get("sha") != "main" {
+			http.NotFound(w, r)
+			return
+		}
+
+		page := pageNumber(r)
+		requested = append(requested, page)
+		setLink(w, r, page, pages)
+		var commits []map[string]string
+		for i := 1; i <= 2; i++ {
+			commits = append(commits, map[string]string{"sha": fmt.Sprintf("c%d-%d", page, i)})
+		}
+		writeJSON(t, w, commits)
+	})
+
+	commits, err := client.GetCommits("org", "template", "main", time.Time{}, 3)
+	if err != nil {
+		t.Fatalf("GetCommits: %v", err)
+	}
+	var shas []string
+	for _, commit := range commits {
+		shas = append(shas, commit.SHA)
+	}
+	if want := []string{"c1-1", "c1-2", "c2-1"}; !reflect.DeepEqual(shas, want) {
+		t.Errorf("commits = %v, want %v", shas, want)
+	}
+	if want := []int{1, 2}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested pages %v, want %v", requested, want)
+	}
+}
+
+func TestCompareCommitsJoinsPages(t *testing.T) {
+	pages := [][]string{{"a", "b"}, {"c"}}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/repos/org/template/compare/base...head" {
+			http.NotFound(w, r)
+			return
+		}
+
+		page := pageNumber(r)
+		setLink(w, r, page, len(pages))
+		var commits []map[string]string
+		for _, sha := range pages[page-1] {
+			commits = append(commits, map[string]string{"sha": sha})
+		}
+		writeJSON(t, w, map[string]any{
+			"status":    "ahead",
+			"ahead_by":  3,
+			"behind_by": 0,
+			"commits":   commits,
+		})
+	})
+
+	comparison, err := client.CompareCommits("org", "template", "base", "head")
+	if err != nil {
+		t.Fatalf("CompareCommits: %v", err)
+	}
+	if comparison.Status != "ahead" || comparison.AheadBy != 3 {
+		t.Errorf("comparison is %s by %d, want ahead by 3", comparison.Status, comparison.AheadBy)
+	}
+	var shas []string
+	for _, commit := range comparison.Commits {
+		shas = append(shas, commit.SHA)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(shas, want) {
+		t.Errorf("commits = %v, want %v", shas, want)
+	}
+}
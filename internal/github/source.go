@@ -0,0 +1,88 @@
+package github
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+func init() {
+	provider.Register("github", NewSource)
+}
+
+// Source is the GitHub implementation of provider.SourceProvider for one repository
+type Source struct {
+	Client *Client
+	Owner  string
+	Repo   string
+}
+
+// NewSource creates a Source for a repository given as owner/repo
+func NewSource(location string, opts provider.Options) (provider.SourceProvider, error) {
+	owner, repo, ok := strings.Cut(location, "/")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, "/") {
+		return nil, fmt.Errorf("invalid GitHub repository %q, expected owner/repo", location)
+	}
+	return &Source{Client: NewClient(opts.Token, opts.Server), Owner: owner, Repo: repo}, nil
+}
+
+// ListTags returns all tags of the repository
+func (s *Source) ListTags() ([]string, error) {
+	return s.Client.GetTags(s.Owner, s.Repo, 0)
+}
+
+// ListBranches returns all branches of the repository
+func (s *Source) ListBranches() ([]string, error) {
+	return s.Client.GetBranches(s.Owner, s.Repo, 0)
+}
+
+// DefaultBranch returns the default branch of the repository
+func (s *Source) DefaultBranch() (string, error) {
+	return s.Client.GetDefaultBranch(s.Owner, s.Repo)
+}
+
+// ResolveRef returns the commit SHA a branch, tag or SHA points to
+func (s *Source) ResolveRef(ref string) (string, error) {
+	commit, err := s.Client.GetCommit(s.Owner, s.Repo, ref)
+	if err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
+
+// DownloadArchive downloads the zipball of the repository at ref
+func (s *Source) DownloadArchive(ref string) ([]byte, error) {
+	return s.Client.DownloadZip(s.Owner, s.Repo, ref)
+}
+
+// ListCommits returns the commits between base and head using the compare API
+func (s *Source) ListCommits(base, head string) (*model.Comparison, error) {
+	return s.Client.CompareCommits(s.Owner, s.Repo, base, head)
+}
+
+// GetCommit returns a single commit
+func (s *Source) GetCommit(sha string) (*model.CommitInfo, error) {
+	return s.Client.GetCommit(s.Owner, s.Repo, sha)
+}
+
+// GetDiff returns the diff of a single commit
+func (s *Source) GetDiff(sha string) ([]byte, error) {
+	return s.Client.GetDiff(s.Owner, s.Repo, sha)
+}
+
+// APIURL returns the API root of the GitHub server
+func (s *Source) APIURL() string {
+	return s.Client.BaseURL
+}
+
+// Quota reports the remaining API quota, if GitHub has reported it yet
+func (s *Source) Quota() string {
+	rl := s.Client.RateLimit()
+	if rl == nil {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d requests left, resets at %s", rl.Remaining, rl.Limit, rl.Reset.Format(time.RFC3339))
+}
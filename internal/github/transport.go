@@ -0,0 +1,78 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"templatamus/internal/provider"
+)
+
+// Errors returned by the client that callers can check with errors.Is. They are
+// the provider errors, so callers don't need to know which backend they talk to.
+var (
+	ErrNotFound     = provider.ErrNotFound
+	ErrUnauthorized = provider.ErrUnauthorized
+	ErrRateLimited  = provider.ErrRateLimited
+)
+
+// lowQuotaThreshold is the remaining quota below which a warning is printed
+const lowQuotaThreshold = 50
+
+// RateLimit is the API quota reported by the last response
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// RateLimit returns the quota reported by the last response, or nil if none was seen yet
+func (c *Client) RateLimit() *RateLimit {
+	return c.rateLimit
+}
+
+// newTransport creates the transport the client's requests go through, which
+// sends its token and tracks the quota GitHub reports
+func (c *Client) newTransport() *provider.Transport {
+	return &provider.Transport{
+		Server: "GitHub",
+		Authorize: func(req *http.Request) {
+			if c.Token != "" {
+				req.Header.Set("Authorization", "token "+c.Token)
+			}
+		},
+		Observe: func(resp *http.Response) {
+			c.updateRateLimit(resp.Header)
+		},
+	}
+}
+
+// get performs a GET request through the shared provider transport. Any
+// response other than 200 is returned as a *provider.APIError.
+func (c *Client) get(url, accept string) (*http.Response, error) {
+	return c.transport.Get(url, accept)
+}
+
+// updateRateLimit records the quota reported in the response headers and
+// warns once it is running low
+func (c *Client) updateRateLimit(header http.Header) {
+	limit, err := strconv.Atoi(header.Get("X-RateLimit-Limit"))
+	if err != nil {
+		return
+	}
+	remaining, _ := strconv.Atoi(header.Get("X-RateLimit-Remaining"))
+	reset, _ := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+
+	c.rateLimit = &RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}
+
+	if remaining < lowQuotaThreshold && !c.warnedLowQuota {
+		c.warnedLowQuota = true
+		fmt.Printf("Warning: only %d of %d GitHub API requests left, resets at %s\n",
+			remaining, limit, c.rateLimit.Reset.Format(time.Kitchen))
+	}
+}
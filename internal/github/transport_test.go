@@ -0,0 +1,132 @@
+package github
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"templatamus/internal/provider"
+)
+
+func TestGetRetries(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+	}{
+		{name: "secondary rate limit", status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "0"}},
+		{
+			// The quota has already reset by the time we look, so there is nothing to wait for
+			name:   "exhausted quota",
+			status: http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Limit":     "60",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(time.Now().Add(-time.Second).Unix(), 10),
+			},
+		},
+		{name: "server error", status: http.StatusBadGateway},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				if requests == 1 {
+					for name, value := range tt.header {
+						w.Header().Set(name, value)
+					}
+					http.Error(w, `{"message":"try again"}`, tt.status)
+					return
+				}
+				writeJSON(t, w, map[string]string{"default_branch": "main"})
+			})
+
+			branch, err := client.GetDefaultBranch("org", "template")
+			if err != nil {
+				t.Fatalf("GetDefaultBranch: %v", err)
+			}
+			if branch != "main" {
+				t.Errorf("default branch = %q, want main", branch)
+			}
+			if requests != 2 {
+				t.Errorf("got %d requests, want 2", requests)
+			}
+		})
+	}
+}
+
+func TestErrorsMapToProviderErrors(t *testing.T) {
+	reset := time.Now().Add(time.Hour).Truncate(time.Second)
+
+	tests := []struct {
+		name   string
+		status int
+		header map[string]string
+		want   error
+	}{
+		{name: "bad token", status: http.StatusUnauthorized, want: ErrUnauthorized},
+		{name: "no access", status: http.StatusForbidden, want: ErrUnauthorized},
+		{name: "missing repository", status: http.StatusNotFound, want: ErrNotFound},
+		// Waits longer than the transport is willing to sleep fail right away
+		{name: "long secondary rate limit", status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "3600"}, want: ErrRateLimited},
+		{
+			name:   "exhausted quota",
+			status: http.StatusForbidden,
+			header: map[string]string{
+				"X-RateLimit-Limit":     "60",
+				"X-RateLimit-Remaining": "0",
+				"X-RateLimit-Reset":     strconv.FormatInt(reset.Unix(), 10),
+			},
+			want: ErrRateLimited,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for name, value := range tt.header {
+					w.Header().Set(name, value)
+				}
+				http.Error(w, `{"message":"nope"}`, tt.status)
+			})
+
+			_, err := client.GetDefaultBranch("org", "template")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			var apiErr *provider.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Fatalf("error = %#v, want an *provider.APIError with status %d", err, tt.status)
+			}
+			if tt.header["X-RateLimit-Reset"] != "" && !apiErr.ResetAt.Equal(reset) {
+				t.Errorf("rate limit resets at %v, want %v", apiErr.ResetAt, reset)
+			}
+			if requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+		})
+	}
+}
+
+func TestRateLimitIsTracked(t *testing.T) {
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Limit", "5000")
+		w.Header().Set("X-RateLimit-Remaining", "4999")
+		w.Header().Set("X-RateLimit-Reset", "1700000000")
+		writeJSON(t, w, map[string]string{"default_branch": "main"})
+	})
+
+	if _, err := client.GetDefaultBranch("org", "template"); err != nil {
+		t.Fatalf("GetDefaultBranch: %v", err)
+	}
+	limit := client.RateLimit()
+	if limit == nil || limit.Limit != 5000 || limit.Remaining != 4999 || !limit.Reset.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("rate limit = %+v, want 4999 of 5000 left", limit)
+	}
+}
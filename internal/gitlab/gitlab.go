@@ -0,0 +1,332 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+// DefaultAPIURL is the API of gitlab.com, used when no other server is configured
+const DefaultAPIURL = "https://gitlab.com/api/v4"
+
+// perPage is the page size requested from list endpoints, the maximum GitLab allows
+const perPage = 100
+
+// Client represents a GitLab v4 API client
+type Client struct {
+	Token string
+	// BaseURL is the API root, e.g. https://gitlab.example.com/api/v4 for a self-managed instance
+	BaseURL string
+	// DownloadURL is the root archives are downloaded from, the API root unless configured otherwise
+	DownloadURL string
+
+	transport *provider.Transport
+}
+
+// NewClient creates a new GitLab client for the given server
+func NewClient(token string, server model.ServerConfig) *Client {
+	baseURL := strings.TrimSuffix(server.APIURL, "/")
+	if baseURL == "" {
+		baseURL = DefaultAPIURL
+	}
+	downloadURL := strings.TrimSuffix(server.DownloadURL, "/")
+	if downloadURL == "" {
+		downloadURL = baseURL
+	}
+	c := &Client{Token: token, BaseURL: baseURL, DownloadURL: downloadURL}
+	c.transport = &provider.Transport{
+		Server: "GitLab",
+		Authorize: func(req *http.Request) {
+			if c.Token != "" {
+				req.Header.Set("PRIVATE-TOKEN", c.Token)
+			}
+		},
+	}
+	return c
+}
+
+// GetTags retrieves all tags for a project
+func (c *Client) GetTags(project string) ([]string, error) {
+	return c.getNames(c.projectURL(project, "/repository/tags"))
+}
+
+// GetBranches retrieves all branches for a project
+func (c *Client) GetBranches(project string) ([]string, error) {
+	return c.getNames(c.projectURL(project, "/repository/branches"))
+}
+
+// getNames collects the name field of every item in a paginated list
+func (c *Client) getNames(endpoint string) ([]string, error) {
+	result := []string{}
+	err := c.getPaged(endpoint, func(body io.Reader) error {
+		var items []struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(body).Decode(&items); err != nil {
+			return err
+		}
+		for _, item := range items {
+			result = append(result, item.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetDefaultBranch retrieves the default branch for a project
+func (c *Client) GetDefaultBranch(project string) (string, error) {
+	var data struct {
+		DefaultBranch string `json:"default_branch"`
+	}
+	if err := c.getJSON(c.projectURL(project, ""), &data); err != nil {
+		return "", err
+	}
+	return data.DefaultBranch, nil
+}
+
+// DownloadZip downloads a project as a zip archive
+func (c *Client) DownloadZip(project, ref string) ([]byte, error) {
+	endpoint := fmt.Sprintf("%s/projects/%s/repository/archive.zip?sha=%s", c.DownloadURL, url.PathEscape(project), url.QueryEscape(ref))
+	resp, err := c.get(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	return io.ReadAll(resp.Body)
+}
+
+// GetCommit retrieves a single commit
+func (c *Client) GetCommit(project, sha string) (*model.CommitInfo, error) {
+	var commit glCommit
+	if err := c.getJSON(c.projectURL(project, "/repository/commits/"+url.PathEscape(sha)), &commit); err != nil {
+		return nil, err
+	}
+	info := commit.toCommitInfo()
+	return &info, nil
+}
+
+// CompareCommits lists the commits reachable from head but not from base, oldest first
+func (c *Client) CompareCommits(project, base, head string) (*model.Comparison, error) {
+	ahead, err := c.compare(project, base, head)
+	if err != nil {
+		return nil, err
+	}
+
+	comparison := &model.Comparison{Commits: ahead, AheadBy: len(ahead)}
+
+	// GitLab doesn't report how the refs relate, so count the other direction too
+	behind, err := c.compare(project, head, base)
+	if err != nil {
+		return nil, err
+	}
+	comparison.BehindBy = len(behind)
+
+	switch {
+	case comparison.AheadBy == 0 && comparison.BehindBy == 0:
+		comparison.Status = "identical"
+	case comparison.BehindBy == 0:
+		comparison.Status = "ahead"
+	case comparison.AheadBy == 0:
+		comparison.Status = "behind"
+	default:
+		comparison.Status = "diverged"
+	}
+
+	return comparison, nil
+}
+
+// compare returns the commits in to that aren't in from, oldest first
+func (c *Client) compare(project, from, to string) ([]model.CommitInfo, error) {
+	endpoint := c.projectURL(project, fmt.Sprintf("/repository/compare?from=%s&to=%s&straight=false",
+		url.QueryEscape(from), url.QueryEscape(to)))
+
+	var data struct {
+		Commits []glCommit `json:"commits"`
+	}
+	if err := c.getJSON(endpoint, &data); err != nil {
+		return nil, err
+	}
+
+	commits := make([]model.CommitInfo, 0, len(data.Commits))
+	for _, gc := range data.Commits {
+		commits = append(commits, gc.toCommitInfo())
+	}
+	return commits, nil
+}
+
+// GetDiff gets the diff for a commit, rebuilt as a git-style patch from the
+// per-file diffs GitLab returns. GitLab leaves out the diff of binary files and
+// files too large to show, so commits with such changes fail rather than
+// return a patch that silently drops them.
+func (c *Client) GetDiff(project, sha string) ([]byte, error) {
+	var patch strings.Builder
+	err := c.getPaged(c.projectURL(project, "/repository/commits/"+url.PathEscape(sha)+"/diff"), func(body io.Reader) error {
+		var files []glDiff
+		if err := json.NewDecoder(body).Decode(&files); err != nil {
+			return err
+		}
+		for _, f := range files {
+			if err := f.writeTo(&patch); err != nil {
+				return fmt.Errorf("failed to get the diff of commit %s: %w", sha, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(patch.String()), nil
+}
+
+// projectURL builds the URL of a project endpoint; the project is its full path, e.g. group/sub/name
+func (c *Client) projectURL(project, suffix string) string {
+	return fmt.Sprintf("%s/projects/%s%s", c.BaseURL, url.PathEscape(project), suffix)
+}
+
+// getJSON performs a GET request and decodes the JSON response into v
+func (c *Client) getJSON(endpoint string, v interface{}) error {
+	resp, err := c.get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// getPaged performs GET requests for every page of a list, following the
+// X-Next-Page header, and calls handle with the body of each page
+func (c *Client) getPaged(pageURL string, handle func(body io.Reader) error) error {
+	sep := "?"
+	if strings.Contains(pageURL, "?") {
+		sep = "&"
+	}
+
+	page := "1"
+	for page != "" {
+		resp, err := c.get(fmt.Sprintf("%s%sper_page=%d&page=%s", pageURL, sep, perPage, page))
+		if err != nil {
+			return err
+		}
+
+		err = handle(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+// get performs a GET request through the shared provider transport. Any
+// response other than 200 is returned as a *provider.APIError.
+func (c *Client) get(endpoint string) (*http.Response, error) {
+	return c.transport.Get(endpoint, "")
+}
+
+// glCommit is a commit as returned by the GitLab commits API
+type glCommit struct {
+	ID           string    `json:"id"`
+	Message      string    `json:"message"`
+	AuthorName   string    `json:"author_name"`
+	AuthoredDate time.Time `json:"authored_date"`
+	WebURL       string    `json:"web_url"`
+	ParentIDs    []string  `json:"parent_ids"`
+}
+
+// toCommitInfo converts a GitLab commit to our model
+func (gc glCommit) toCommitInfo() model.CommitInfo {
+	return model.CommitInfo{
+		SHA:     gc.ID,
+		Message: gc.Message,
+		Author:  gc.AuthorName,
+		Date:    gc.AuthoredDate,
+		URL:     gc.WebURL,
+		Parents: gc.ParentIDs,
+	}
+}
+
+// glDiff is the change to a single file as returned by the GitLab diff API
+type glDiff struct {
+	OldPath     string `json:"old_path"`
+	NewPath     string `json:"new_path"`
+	AMode       string `json:"a_mode"`
+	BMode       string `json:"b_mode"`
+	NewFile     bool   `json:"new_file"`
+	RenamedFile bool   `json:"renamed_file"`
+	DeletedFile bool   `json:"deleted_file"`
+	Diff        string `json:"diff"`
+	// TooLarge and Collapsed are set when GitLab left out a diff for its size
+	TooLarge  bool `json:"too_large"`
+	Collapsed bool `json:"collapsed"`
+}
+
+// writeTo appends the file's change to patch in git diff format. It fails for
+// changes GitLab returns without their diff, which can't be rebuilt.
+func (d glDiff) writeTo(patch *strings.Builder) error {
+	if err := d.checkComplete(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(patch, "diff --git a/%s b/%s\n", d.OldPath, d.NewPath)
+
+	switch {
+	case d.NewFile:
+		fmt.Fprintf(patch, "new file mode %s\n", d.BMode)
+	case d.DeletedFile:
+		fmt.Fprintf(patch, "deleted file mode %s\n", d.AMode)
+	case d.AMode != d.BMode:
+		fmt.Fprintf(patch, "old mode %s\nnew mode %s\n", d.AMode, d.BMode)
+	}
+	if d.RenamedFile {
+		fmt.Fprintf(patch, "rename from %s\nrename to %s\n", d.OldPath, d.NewPath)
+	}
+
+	// Mode changes, pure renames and empty files have no hunks
+	if d.Diff == "" {
+		return nil
+	}
+
+	oldPath, newPath := "a/"+d.OldPath, "b/"+d.NewPath
+	if d.NewFile {
+		oldPath = "/dev/null"
+	}
+	if d.DeletedFile {
+		newPath = "/dev/null"
+	}
+	fmt.Fprintf(patch, "--- %s\n+++ %s\n", oldPath, newPath)
+	patch.WriteString(d.Diff)
+	if !strings.HasSuffix(d.Diff, "\n") {
+		patch.WriteString("\n")
+	}
+	return nil
+}
+
+// checkComplete reports an error if GitLab left out the diff of a change: one
+// too large to show, or a binary file, whose diff is empty or only says that
+// the files differ although the file was neither just renamed nor had only
+// its mode changed
+func (d glDiff) checkComplete() error {
+	switch {
+	case d.TooLarge || d.Collapsed:
+		return fmt.Errorf("the change to %s is too large for the GitLab API to return", d.NewPath)
+	case strings.HasPrefix(d.Diff, "Binary files "):
+		return fmt.Errorf("%s is a binary file, whose changes the GitLab API doesn't return", d.NewPath)
+	case d.Diff == "" && !d.NewFile && !d.DeletedFile && !d.RenamedFile && d.AMode == d.BMode:
+		return fmt.Errorf("the GitLab API returned no diff for %s, it is likely a binary file", d.NewPath)
+	}
+	return nil
+}
@@ -0,0 +1,292 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+// newTestClient starts a stand-in GitLab API serving handler and returns a
+// client talking to it
+func newTestClient(t *testing.T, handler http.HandlerFunc) *Client {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+	return NewClient("secret", model.ServerConfig{APIURL: server.URL})
+}
+
+// writeJSON writes v as the JSON body of a 200 response
+func writeJSON(t *testing.T, w http.ResponseWriter, v any) {
+	t.Helper()
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		t.Errorf("failed to encode response: %v", err)
+	}
+}
+
+func TestGetTagsFollowsNextPage(t *testing.T) {
+	pages := map[string][]string{
+		"1": {"v1", "v2"},
+		"2": {"v3"},
+		"3": {"v4"},
+	}
+	var requested []string
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/group/sub/app/repository/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		if got := r.Header.Get("PRIVATE-TOKEN"); got != "secret" {
+			t.Errorf("PRIVATE-TOKEN = %q, want %q", got, "secret")
+		}
+		if got := r.URL.Query().Get("per_page"); got != "100" {
+			t.Errorf("per_page = %q, want 100", got)
+		}
+
+		page := r.URL.Query().Get("page")
+		requested = append(requested, page)
+		if next := map[string]string{"1": "2", "2": "3"}[page]; next != "" {
+			w.Header().Set("X-Next-Page", next)
+		}
+		var items []map[string]string
+		for _, name := range pages[page] {
+			items = append(items, map[string]string{"name": name})
+		}
+		writeJSON(t, w, items)
+	})
+
+	tags, err := client.GetTags("group/sub/app")
+	if err != nil {
+		t.Fatalf("GetTags: %v", err)
+	}
+	if want := []string{"v1", "v2", "v3", "v4"}; !reflect.DeepEqual(tags, want) {
+		t.Errorf("tags = %v, want %v", tags, want)
+	}
+	if want := []string{"1", "2", "3"}; !reflect.DeepEqual(requested, want) {
+		t.Errorf("requested pages %v, want %v", requested, want)
+	}
+}
+
+func TestCompareCommitsStatus(t *testing.T) {
+	// history maps "from..to" to the commits in to that aren't in from
+	tests := []struct {
+		name        string
+		history     map[string][]string
+		wantStatus  string
+		wantAhead   int
+		wantBehind  int
+		wantCommits []string
+	}{
+		{
+			name:       "identical",
+			history:    map[string][]string{},
+			wantStatus: "identical",
+		},
+		{
+			name:        "ahead",
+			history:     map[string][]string{"base..head": {"c1", "c2"}},
+			wantStatus:  "ahead",
+			wantAhead:   2,
+			wantCommits: []string{"c1", "c2"},
+		},
+		{
+			name:       "behind",
+			history:    map[string][]string{"head..base": {"b1"}},
+			wantStatus: "behind",
+			wantBehind: 1,
+		},
+		{
+			name:        "diverged",
+			history:     map[string][]string{"base..head": {"c1"}, "head..base": {"b1", "b2"}},
+			wantStatus:  "diverged",
+			wantAhead:   1,
+			wantBehind:  2,
+			wantCommits: []string{"c1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/projects/group/app/repository/compare" {
+					http.NotFound(w, r)
+					return
+				}
+				q := r.URL.Query()
+				if q.Get("straight") != "false" {
+					t.Errorf("straight = %q, want false", q.Get("straight"))
+				}
+				var commits []glCommit
+				for _, sha := range tt.history[q.Get("from")+".."+q.Get("to")] {
+					commits = append(commits, glCommit{ID: sha, ParentIDs: []string{"parent"}})
+				}
+				writeJSON(t, w, map[string]any{"commits": commits})
+			})
+
+			comparison, err := client.CompareCommits("group/app", "base", "head")
+			if err != nil {
+				t.Fatalf("CompareCommits: %v", err)
+			}
+			if comparison.Status != tt.wantStatus || comparison.AheadBy != tt.wantAhead || comparison.BehindBy != tt.wantBehind {
+				t.Errorf("got status %s, ahead %d, behind %d; want %s, %d, %d",
+					comparison.Status, comparison.AheadBy, comparison.BehindBy, tt.wantStatus, tt.wantAhead, tt.wantBehind)
+			}
+			var shas []string
+			for _, commit := range comparison.Commits {
+				shas = append(shas, commit.SHA)
+			}
+			if !reflect.DeepEqual(shas, tt.wantCommits) {
+				t.Errorf("commits = %v, want %v", shas, tt.wantCommits)
+			}
+		})
+	}
+}
+
+func TestGetDiffRebuildsPatch(t *testing.T) {
+	pages := map[string][]glDiff{
+		"1": {
+			{
+				OldPath: "README.md", NewPath: "README.md", AMode: "100644", BMode: "100644",
+				Diff: "@@ -1 +1 @@\n-old\n+new\n",
+			},
+			{
+				OldPath: "new.txt", NewPath: "new.txt", AMode: "0", BMode: "100644", NewFile: true,
+				Diff: "@@ -0,0 +1 @@\n+hello",
+			},
+		},
+		"2": {
+			{
+				OldPath: "gone.txt", NewPath: "gone.txt", AMode: "100644", BMode: "0", DeletedFile: true,
+				Diff: "@@ -1 +0,0 @@\n-bye\n",
+			},
+			{
+				OldPath: "old/name.txt", NewPath: "new/name.txt", AMode: "100644", BMode: "100644", RenamedFile: true,
+			},
+			{
+				OldPath: "run.sh", NewPath: "run.sh", AMode: "100644", BMode: "100755",
+			},
+		},
+	}
+	client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/group/app/repository/commits/abc123/diff" {
+			http.NotFound(w, r)
+			return
+		}
+		page := r.URL.Query().Get("page")
+		if page == "1" {
+			w.Header().Set("X-Next-Page", "2")
+		}
+		writeJSON(t, w, pages[page])
+	})
+
+	diff, err := client.GetDiff("group/app", "abc123")
+	if err != nil {
+		t.Fatalf("GetDiff: %v", err)
+	}
+
+	want := `diff --git a/README.md b/README.md
+--- a/README.md
++++ b/README.md
+@@ -1 +1 @@
+-old
++new
+diff --git a/new.txt b/new.txt
+new file mode 100644
+--- /dev/null
++++ b/new.txt
+@@ -0,0 +1 @@
++hello
+diff --git a/gone.txt b/gone.txt
+deleted file mode 100644
+--- a/gone.txt
++++ /dev/null
+@@ -1 +0,0 @@
+-bye
+diff --git a/old/name.txt b/new/name.txt
+rename from old/name.txt
+rename to new/name.txt
+diff --git a/run.sh b/run.sh
+old mode 100644
+new mode 100755
+`
+	if string(diff) != want {
+		t.Errorf("patch =\n%s\nwant\n%s", diff, want)
+	}
+}
+
+func TestGetDiffFailsWithoutFileDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		diff glDiff
+	}{
+		{name: "too large", diff: glDiff{OldPath: "big.json", NewPath: "big.json", AMode: "100644", BMode: "100644", TooLarge: true}},
+		{name: "collapsed", diff: glDiff{OldPath: "big.json", NewPath: "big.json", AMode: "100644", BMode: "100644", Collapsed: true}},
+		{name: "binary marker", diff: glDiff{OldPath: "logo.png", NewPath: "logo.png", AMode: "100644", BMode: "100644", Diff: "Binary files a/logo.png and b/logo.png differ\n"}},
+		{name: "empty modification", diff: glDiff{OldPath: "logo.png", NewPath: "logo.png", AMode: "100644", BMode: "100644"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(t, w, []glDiff{
+					{OldPath: "README.md", NewPath: "README.md", AMode: "100644", BMode: "100644", Diff: "@@ -1 +1 @@\n-old\n+new\n"},
+					tt.diff,
+				})
+			})
+
+			diff, err := client.GetDiff("group/app", "abc123")
+			if err == nil {
+				t.Fatalf("GetDiff returned a patch without the change to %s:\n%s", tt.diff.NewPath, diff)
+			}
+			if !strings.Contains(err.Error(), tt.diff.NewPath) {
+				t.Errorf("error %q doesn't name %s", err, tt.diff.NewPath)
+			}
+		})
+	}
+}
+
+func TestErrorsMapToProviderErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		header map[string]string
+		want   error
+	}{
+		{status: http.StatusUnauthorized, want: provider.ErrUnauthorized},
+		{status: http.StatusForbidden, want: provider.ErrUnauthorized},
+		{status: http.StatusNotFound, want: provider.ErrNotFound},
+		// A wait longer than the transport is willing to sleep fails right away
+		{status: http.StatusTooManyRequests, header: map[string]string{"Retry-After": "3600"}, want: provider.ErrRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			requests := 0
+			client := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				for name, value := range tt.header {
+					w.Header().Set(name, value)
+				}
+				http.Error(w, `{"message":"nope"}`, tt.status)
+			})
+
+			_, err := client.GetDefaultBranch("group/app")
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+			var apiErr *provider.APIError
+			if !errors.As(err, &apiErr) || apiErr.StatusCode != tt.status {
+				t.Errorf("error = %#v, want an *provider.APIError with status %d", err, tt.status)
+			}
+			if requests != 1 {
+				t.Errorf("got %d requests, want 1", requests)
+			}
+		})
+	}
+}
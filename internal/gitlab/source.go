@@ -0,0 +1,77 @@
+package gitlab
+
+import (
+	"fmt"
+	"strings"
+
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+func init() {
+	provider.Register("gitlab", NewSource)
+}
+
+// Source is the GitLab implementation of provider.SourceProvider for one project
+type Source struct {
+	Client  *Client
+	Project string
+}
+
+// NewSource creates a Source for a project given by its full path, e.g. group/sub/name
+func NewSource(location string, opts provider.Options) (provider.SourceProvider, error) {
+	project := strings.Trim(location, "/")
+	if !strings.Contains(project, "/") {
+		return nil, fmt.Errorf("invalid GitLab project %q, expected group/name", location)
+	}
+	return &Source{Client: NewClient(opts.Token, opts.Server), Project: project}, nil
+}
+
+// ListTags returns all tags of the project
+func (s *Source) ListTags() ([]string, error) {
+	return s.Client.GetTags(s.Project)
+}
+
+// ListBranches returns all branches of the project
+func (s *Source) ListBranches() ([]string, error) {
+	return s.Client.GetBranches(s.Project)
+}
+
+// DefaultBranch returns the default branch of the project
+func (s *Source) DefaultBranch() (string, error) {
+	return s.Client.GetDefaultBranch(s.Project)
+}
+
+// ResolveRef returns the commit SHA a branch, tag or SHA points to
+func (s *Source) ResolveRef(ref string) (string, error) {
+	commit, err := s.Client.GetCommit(s.Project, ref)
+	if err != nil {
+		return "", err
+	}
+	return commit.SHA, nil
+}
+
+// DownloadArchive downloads the zip archive of the project at ref
+func (s *Source) DownloadArchive(ref string) ([]byte, error) {
+	return s.Client.DownloadZip(s.Project, ref)
+}
+
+// ListCommits returns the commits between base and head using the compare API
+func (s *Source) ListCommits(base, head string) (*model.Comparison, error) {
+	return s.Client.CompareCommits(s.Project, base, head)
+}
+
+// GetCommit returns a single commit
+func (s *Source) GetCommit(sha string) (*model.CommitInfo, error) {
+	return s.Client.GetCommit(s.Project, sha)
+}
+
+// GetDiff returns the diff of a single commit
+func (s *Source) GetDiff(sha string) ([]byte, error) {
+	return s.Client.GetDiff(s.Project, sha)
+}
+
+// APIURL returns the API root of the GitLab server
+func (s *Source) APIURL() string {
+	return s.Client.BaseURL
+}
@@ -0,0 +1,123 @@
+// Package ignore matches slash-separated paths against gitignore-style patterns
+package ignore
+
+import (
+	"path"
+	"strings"
+)
+
+// pattern is a single parsed line of an ignore file
+type pattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// Rules is an ordered list of gitignore-style patterns. As in .gitignore, the
+// last pattern that matches a path decides whether it is ignored, a leading !
+// re-includes a path, a trailing / only matches directories, a pattern with a
+// slash in it is anchored at the root and ** matches any number of directories.
+type Rules struct {
+	patterns []pattern
+}
+
+// Add appends the lines of an ignore file to the rules, so they take precedence
+// over the earlier ones. Blank lines and comments are skipped.
+func (r *Rules) Add(lines ...string) {
+	for _, line := range lines {
+		if p, ok := parsePattern(line); ok {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+}
+
+// Empty reports whether the rules have no patterns
+func (r *Rules) Empty() bool {
+	return r == nil || len(r.patterns) == 0
+}
+
+// Match reports whether the file at the given slash-separated path is ignored,
+// either itself or because one of its parent directories is
+func (r *Rules) Match(name string) bool {
+	if r.Empty() {
+		return false
+	}
+
+	// Like git, a file can't be re-included once its directory is ignored
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		if r.match(segments[:i], true) {
+			return true
+		}
+	}
+	return r.match(segments, false)
+}
+
+// match returns the verdict of the last pattern matching a path
+func (r *Rules) match(segments []string, isDir bool) bool {
+	ignored := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(p.segments, segments) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+// parsePattern parses a line of an ignore file, reporting false for lines
+// that hold no pattern
+func parsePattern(line string) (pattern, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern{}, false
+	}
+
+	var p pattern
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		// \! and \# escape a leading ! or #, other escapes are left to path.Match
+		line = line[1:]
+	}
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if line == "" {
+		return pattern{}, false
+	}
+
+	// Patterns without a slash match at any depth
+	if !strings.Contains(line, "/") {
+		line = "**/" + line
+	}
+	p.segments = strings.Split(strings.TrimPrefix(line, "/"), "/")
+	return p, true
+}
+
+// matchSegments matches path segments against pattern segments, where a **
+// segment matches zero or more path segments
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		return len(segments) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segments) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], segments[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segments[1:])
+}
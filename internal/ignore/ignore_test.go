@@ -0,0 +1,61 @@
+package ignore
+
+import "testing"
+
+func TestRulesMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		want     bool
+	}{
+		// Patterns without a slash match at any depth
+		{name: "unanchored at root", patterns: []string{"*.log"}, path: "debug.log", want: true},
+		{name: "unanchored nested", patterns: []string{"*.log"}, path: "logs/app/debug.log", want: true},
+		{name: "unanchored other name", patterns: []string{"*.log"}, path: "debug.txt", want: false},
+
+		// A leading or inner slash anchors the pattern at the root
+		{name: "leading slash at root", patterns: []string{"/build"}, path: "build", want: true},
+		{name: "leading slash nested", patterns: []string{"/build"}, path: "src/build", want: false},
+		{name: "inner slash at root", patterns: []string{"docs/api.md"}, path: "docs/api.md", want: true},
+		{name: "inner slash nested", patterns: []string{"docs/api.md"}, path: "site/docs/api.md", want: false},
+		{name: "anchored directory ignores contents", patterns: []string{"/build"}, path: "build/out/app", want: true},
+
+		// ** matches any number of directories
+		{name: "leading ** at root", patterns: []string{"**/cache"}, path: "cache", want: true},
+		{name: "leading ** nested", patterns: []string{"**/cache"}, path: "a/b/cache", want: true},
+		{name: "inner ** no directories", patterns: []string{"a/**/b"}, path: "a/b", want: true},
+		{name: "inner ** several directories", patterns: []string{"a/**/b"}, path: "a/x/y/b", want: true},
+		{name: "inner ** other root", patterns: []string{"a/**/b"}, path: "c/x/b", want: false},
+		{name: "trailing **", patterns: []string{"vendor/**"}, path: "vendor/lib/x.go", want: true},
+
+		// A trailing slash only matches directories
+		{name: "dir-only on file", patterns: []string{"tmp/"}, path: "tmp", want: false},
+		{name: "dir-only on directory contents", patterns: []string{"tmp/"}, path: "tmp/file", want: true},
+		{name: "dir-only nested", patterns: []string{"tmp/"}, path: "src/tmp/file", want: true},
+
+		// The last matching pattern wins, and ! re-includes
+		{name: "negated", patterns: []string{"*.md", "!README.md"}, path: "README.md", want: false},
+		{name: "negation leaves others", patterns: []string{"*.md", "!README.md"}, path: "CHANGES.md", want: true},
+		{name: "negation overridden later", patterns: []string{"*.md", "!README.md", "README.md"}, path: "README.md", want: true},
+		{name: "no re-include under ignored dir", patterns: []string{"docs/", "!docs/keep.md"}, path: "docs/keep.md", want: true},
+		{name: "re-include when only contents ignored", patterns: []string{"docs/*", "!docs/keep.md"}, path: "docs/keep.md", want: false},
+
+		// Comments, blank lines and escapes
+		{name: "comment ignored", patterns: []string{"# notes", ""}, path: "# notes", want: false},
+		{name: "escaped hash", patterns: []string{`\#notes`}, path: "#notes", want: true},
+		{name: "escaped bang", patterns: []string{`\!important`}, path: "!important", want: true},
+		{name: "escaped bang is not negation", patterns: []string{"*", `\!important`}, path: "!important", want: true},
+		{name: "no patterns", patterns: nil, path: "anything", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rules Rules
+			rules.Add(tt.patterns...)
+			if got := rules.Match(tt.path); got != tt.want {
+				t.Errorf("Match(%q) with %q = %v, want %v", tt.path, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
@@ -1,23 +1,190 @@
 package model
 
 import (
+	"encoding/json"
 	"time"
 )
 
 // UserConfig represents the user's global configuration stored in ~/.templatamus
 type UserConfig struct {
-	Token string   `json:"token"`
-	Repos []string `json:"repos"`
+	Token string `json:"token"`
+	// Tokens holds the tokens for specific hosts, such as github.example.com,
+	// which are used instead of Token for that host
+	Tokens map[string]string `json:"tokens,omitempty"`
+	ServerConfig
+	Repos []RepoConfig `json:"repos"`
 }
 
+// ServerConfig holds the URLs of a GitHub server; empty fields default to github.com
+type ServerConfig struct {
+	APIURL      string `json:"api_url,omitempty"`
+	DownloadURL string `json:"download_url,omitempty"`
+}
+
+// RepoConfig represents an entry in the repos list. It can be written either as a
+// plain "owner/repo" string or as an object that overrides the server URLs.
+type RepoConfig struct {
+	Name string `json:"name"`
+	ServerConfig
+}
+
+// UnmarshalJSON accepts both the string and the object form of a repo entry
+func (r *RepoConfig) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*r = RepoConfig{Name: name}
+		return nil
+	}
+
+	type plain RepoConfig
+	return json.Unmarshal(data, (*plain)(r))
+}
+
+// RepoNames returns the names of all configured repos
+func (c *UserConfig) RepoNames() []string {
+	names := make([]string, len(c.Repos))
+	for i, repo := range c.Repos {
+		names[i] = repo.Name
+	}
+	return names
+}
+
+// FindRepo returns the configured entry for the named repo
+func (c *UserConfig) FindRepo(name string) (RepoConfig, bool) {
+	for _, repo := range c.Repos {
+		if repo.Name == name {
+			return repo, true
+		}
+	}
+	return RepoConfig{}, false
+}
+
+// ServerFor returns the server URLs for the named repo, falling back to the global ones
+func (c *UserConfig) ServerFor(name string) ServerConfig {
+	server := c.RepoServer(name)
+	if server.APIURL == "" {
+		server.APIURL = c.APIURL
+	}
+	if server.DownloadURL == "" {
+		server.DownloadURL = c.DownloadURL
+	}
+	return server
+}
+
+// RepoServer returns the server URLs set on the named repo's own entry, without
+// the global ones
+func (c *UserConfig) RepoServer(name string) ServerConfig {
+	repo, _ := c.FindRepo(name)
+	return repo.ServerConfig
+}
+
+// MetadataSchemaVersion is the version of the metadata.json layout this build writes
+const MetadataSchemaVersion = 2
+
+// Kinds of ref a project can follow, which decide what sync offers
+const (
+	// RefTypeBranch projects are offered the new commits on the branch
+	RefTypeBranch = "branch"
+	// RefTypeTag projects are offered the tags newer than theirs
+	RefTypeTag = "tag"
+	// RefTypeCommit projects are pinned and only move with an explicit upgrade
+	RefTypeCommit = "commit"
+)
+
 // ProjectMetadata represents the metadata stored in the .templatamus/metadata.json file
 type ProjectMetadata struct {
-	SourceRepo     string    `json:"source_repo"`
-	SourceBranch   string    `json:"source_branch"`
-	SourceCommit   string    `json:"source_commit"`
+	// SchemaVersion is the layout version the file was written with
+	SchemaVersion int `json:"schema_version"`
+	// SourceAPIURL is the API of the server the project was created from. It is
+	// empty for projects created before it was recorded.
+	SourceAPIURL string `json:"source_api_url,omitempty"`
+	// SourceDownloadURL is the root the project's archives were downloaded
+	// from. It is empty for sources without an API and for projects created
+	// before it was recorded.
+	SourceDownloadURL string `json:"source_download_url,omitempty"`
+	SourceRepo        string `json:"source_repo"`
+	// RefType is one of the RefType constants. It is empty for projects
+	// migrated from metadata that didn't record it, until sync looks it up.
+	RefType string `json:"ref_type"`
+	// Ref is the branch, tag or commit SHA the project follows
+	Ref string `json:"ref"`
+	// ResolvedSHA is the commit Ref pointed to when the project was created or
+	// last upgraded. Branch syncs don't move it; they start from the last
+	// synced commit recorded in git.UpstreamRef and AppliedCommits.
+	ResolvedSHA    string    `json:"resolved_sha"`
 	CreatedAt      time.Time `json:"created_at"`
 	LastSyncedAt   time.Time `json:"last_synced_at"`
 	AppliedCommits []string  `json:"applied_commits"`
+	// Variables holds the template variable values the project was created with
+	Variables map[string]string `json:"variables,omitempty"`
+	// SkippedCommits lists the upstream commits the user chose not to apply
+	SkippedCommits []SkippedCommit `json:"skipped_commits,omitempty"`
+}
+
+// SkippedCommit records an upstream commit that was skipped and why
+type SkippedCommit struct {
+	SHA       string    `json:"sha"`
+	Reason    string    `json:"reason"`
+	SkippedAt time.Time `json:"skipped_at"`
+}
+
+// FindSkipped returns the record of a skipped commit
+func (m *ProjectMetadata) FindSkipped(sha string) (SkippedCommit, bool) {
+	for _, skipped := range m.SkippedCommits {
+		if skipped.SHA == sha {
+			return skipped, true
+		}
+	}
+	return SkippedCommit{}, false
+}
+
+// MarkSkipped records a commit as skipped, replacing any earlier record of it
+func (m *ProjectMetadata) MarkSkipped(sha, reason string) {
+	m.dropSkipped(sha)
+	m.SkippedCommits = append(m.SkippedCommits, SkippedCommit{SHA: sha, Reason: reason, SkippedAt: time.Now()})
+}
+
+// MarkApplied records a commit as applied, so it is no longer listed as skipped
+func (m *ProjectMetadata) MarkApplied(sha string) {
+	m.AppliedCommits = append(m.AppliedCommits, sha)
+	m.dropSkipped(sha)
+}
+
+// dropSkipped removes the record of a skipped commit
+func (m *ProjectMetadata) dropSkipped(sha string) {
+	var kept []SkippedCommit
+	for _, skipped := range m.SkippedCommits {
+		if skipped.SHA != sha {
+			kept = append(kept, skipped)
+		}
+	}
+	m.SkippedCommits = kept
+}
+
+// Template variable types supported in a TemplateManifest
+const (
+	VariableTypeString = "string"
+	VariableTypeBool   = "bool"
+	VariableTypeChoice = "choice"
+)
+
+// TemplateManifest represents the templatamus.yaml file a template repository can declare
+type TemplateManifest struct {
+	Variables  []TemplateVariable `yaml:"variables"`
+	Exclude    []string           `yaml:"exclude"`
+	PostCreate []string           `yaml:"post_create"`
+	// SyncIgnore holds default .templatamusignore patterns for projects created from the template
+	SyncIgnore []string `yaml:"sync_ignore"`
+}
+
+// TemplateVariable describes a variable a template needs when a project is created
+type TemplateVariable struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Type        string   `yaml:"type"`
+	Default     string   `yaml:"default"`
+	Validation  string   `yaml:"validation"`
+	Choices     []string `yaml:"choices"`
 }
 
 // CommitInfo represents information about a commit in the source repository
@@ -27,7 +194,30 @@ type CommitInfo struct {
 	Author    string    `json:"author"`
 	Date      time.Time `json:"date"`
 	URL       string    `json:"url"`
+	Parents   []string  `json:"parents,omitempty"`
 	IsApplied bool      `json:"-"` // Not stored, calculated at runtime
+	// SkipReason is why the commit was skipped before, if it was. Not stored,
+	// filled in from the project metadata.
+	SkipReason string `json:"-"`
+}
+
+// IsMerge reports whether the commit has more than one parent
+func (c CommitInfo) IsMerge() bool {
+	return len(c.Parents) > 1
+}
+
+// Comparison represents the commits between two points in a source repository's history
+type Comparison struct {
+	Status   string       `json:"status"` // ahead, behind, diverged or identical
+	AheadBy  int          `json:"ahead_by"`
+	BehindBy int          `json:"behind_by"`
+	Commits  []CommitInfo `json:"commits"`
+}
+
+// RejectedFile is a file with hunks that couldn't be applied, left in a .rej file next to it
+type RejectedFile struct {
+	Path  string `json:"path"`
+	Hunks int    `json:"hunks"`
 }
 
 // SyncStatus represents the current status of a sync operation
@@ -37,4 +227,18 @@ type SyncStatus struct {
 	HasConflicts   bool       `json:"has_conflicts"`
 	ConflictsAt    time.Time  `json:"conflicts_at"`
 	ConflictCommit *CommitInfo `json:"conflict_commit,omitempty"`
+	// ConflictedFiles lists the files the merge left conflict markers in
+	ConflictedFiles []string `json:"conflicted_files,omitempty"`
+	// RejectedFiles lists the files whose changes were written to .rej files
+	RejectedFiles []RejectedFile `json:"rejected_files,omitempty"`
+	// UpstreamTree is the rendered template tree of the commit being applied
+	UpstreamTree string `json:"upstream_tree,omitempty"`
+	// Queue holds the selected commits that haven't been applied yet, in order
+	Queue []CommitInfo `json:"queue,omitempty"`
+	// StartHead is the commit HEAD pointed to when the sync started
+	StartHead string `json:"start_head,omitempty"`
+	// StartUpstream is the commit the template baseline ref pointed to when the sync started
+	StartUpstream string `json:"start_upstream,omitempty"`
+	// StartMetadata is the project metadata as it was when the sync started
+	StartMetadata *ProjectMetadata `json:"start_metadata,omitempty"`
 } 
\ No newline at end of file
@@ -0,0 +1,31 @@
+// Package paths resolves the paths given on the command line and in the config
+package paths
+
+import (
+	"fmt"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// Expand expands a leading ~ to the user's home directory and ~name to the
+// home directory of the user called name, the way the shell does
+func Expand(path string) (string, error) {
+	rest, ok := strings.CutPrefix(path, "~")
+	if !ok {
+		return path, nil
+	}
+
+	name, rest, _ := strings.Cut(rest, "/")
+	var u *user.User
+	var err error
+	if name == "" {
+		u, err = user.Current()
+	} else {
+		u, err = user.Lookup(name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to expand %s: %w", path, err)
+	}
+	return filepath.Join(u.HomeDir, rest), nil
+}
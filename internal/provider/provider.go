@@ -0,0 +1,138 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"templatamus/internal/model"
+)
+
+// Errors providers return that callers can check with errors.Is
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrRateLimited  = errors.New("rate limited")
+)
+
+// DefaultScheme is used for repos written without a scheme, e.g. "org/repo"
+const DefaultScheme = "github"
+
+// SourceProvider is a template source bound to a single repository. The create
+// and sync flows only talk to templates through this interface.
+type SourceProvider interface {
+	// ListTags returns the tags of the repository
+	ListTags() ([]string, error)
+	// ListBranches returns the branches of the repository
+	ListBranches() ([]string, error)
+	// DefaultBranch returns the branch used when no ref is chosen
+	DefaultBranch() (string, error)
+	// ResolveRef returns the commit SHA a branch, tag or SHA points to
+	ResolveRef(ref string) (string, error)
+	// DownloadArchive returns the tree at ref as a zip archive with a single root directory
+	DownloadArchive(ref string) ([]byte, error)
+	// ListCommits returns the commits reachable from head but not from base, oldest first
+	ListCommits(base, head string) (*model.Comparison, error)
+	// GetCommit returns a single commit
+	GetCommit(sha string) (*model.CommitInfo, error)
+	// GetDiff returns the changes a commit made as a git-style diff
+	GetDiff(sha string) ([]byte, error)
+	// APIURL returns the API the provider talks to, or "" if it doesn't use one
+	APIURL() string
+}
+
+// QuotaReporter is implemented by providers that can report their remaining API quota
+type QuotaReporter interface {
+	Quota() string
+}
+
+// Options holds what a provider needs to reach its server
+type Options struct {
+	Token  string
+	Server model.ServerConfig
+}
+
+// Factory creates a provider for the given location, which is the repo string
+// without its scheme
+type Factory func(location string, opts Options) (SourceProvider, error)
+
+var factories = make(map[string]Factory)
+
+// Register makes a provider available under the given scheme. It is meant to
+// be called from the init function of the package implementing the provider.
+func Register(scheme string, factory Factory) {
+	if _, ok := factories[scheme]; ok {
+		panic(fmt.Sprintf("provider: scheme %s registered twice", scheme))
+	}
+	factories[scheme] = factory
+}
+
+// Schemes returns the registered schemes in sorted order
+func Schemes() []string {
+	schemes := make([]string, 0, len(factories))
+	for scheme := range factories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// ParseRepo splits a repo string into its scheme and location. It understands
+// "scheme:location" (github:org/x, gitlab:group/x), URL-style schemes such as
+// git+ssh://host/x, whose location keeps the URL without the "git+" prefix,
+// and local paths given as file:///path or as a plain path starting with
+// /, ./, ../ or ~. Any other URL, such as https://host/x or ssh://host/x, is
+// cloned with git. Repos without a scheme use DefaultScheme.
+func ParseRepo(repo string) (string, string) {
+	if path, ok := strings.CutPrefix(repo, "file://"); ok {
+		return "file", path
+	}
+	if isLocalPath(repo) {
+		return "file", repo
+	}
+	if rest, ok := strings.CutPrefix(repo, "git+"); ok && strings.Contains(rest, "://") {
+		return "git", rest
+	}
+	if scheme, _, ok := strings.Cut(repo, "://"); ok && isScheme(scheme) {
+		return "git", repo
+	}
+	if scheme, location, ok := strings.Cut(repo, ":"); ok && !strings.HasPrefix(location, "//") && isScheme(scheme) {
+		return scheme, location
+	}
+	return DefaultScheme, repo
+}
+
+// New creates the provider for a repo string
+func New(repo string, opts Options) (SourceProvider, error) {
+	scheme, location := ParseRepo(repo)
+	factory, ok := factories[scheme]
+	if !ok {
+		return nil, fmt.Errorf("unsupported source %q: unknown scheme %q (supported: %s)",
+			repo, scheme, strings.Join(Schemes(), ", "))
+	}
+	return factory(location, opts)
+}
+
+// isLocalPath reports whether repo is written as a filesystem path
+func isLocalPath(repo string) bool {
+	for _, prefix := range []string{"/", "./", "../", "~"} {
+		if strings.HasPrefix(repo, prefix) {
+			return true
+		}
+	}
+	return repo == "." || repo == ".."
+}
+
+// isScheme reports whether s looks like a scheme name
+func isScheme(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') && r != '+' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
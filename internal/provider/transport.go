@@ -0,0 +1,193 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// maxRetries is how many times a request is retried after a transient failure
+	maxRetries = 3
+	// initialBackoff is the wait before the first retry, doubled for each further one
+	initialBackoff = time.Second
+	// maxRateLimitWait is the longest we wait for a rate limit to clear before giving up
+	maxRateLimitWait = 2 * time.Minute
+)
+
+// httpClient is shared by all transports so connections are reused
+var httpClient = &http.Client{
+	Timeout: 5 * time.Minute,
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+	},
+}
+
+// APIError is returned for unsuccessful API responses. It wraps one of
+// ErrNotFound, ErrUnauthorized or ErrRateLimited when the status matches.
+type APIError struct {
+	// Server names the API the response came from, e.g. GitHub
+	Server     string
+	StatusCode int
+	Status     string
+	Body       string
+	// ResetAt is when the rate limit resets, set for rate limit errors that report it
+	ResetAt time.Time
+	err     error
+}
+
+func (e *APIError) Error() string {
+	if errors.Is(e.err, ErrRateLimited) && !e.ResetAt.IsZero() {
+		return fmt.Sprintf("%s API error: %s: rate limit exceeded, resets at %s", e.Server, e.Status, e.ResetAt.Format(time.Kitchen))
+	}
+	return fmt.Sprintf("%s API error: %s: %s", e.Server, e.Status, e.Body)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.err
+}
+
+// Transport performs the requests of a provider that talks to a hosting API.
+// It retries network errors and 5xx responses with backoff and waits out
+// short rate limits, so every provider handles them the same way.
+type Transport struct {
+	// Server names the API in messages and errors, e.g. GitHub
+	Server string
+	// Authorize adds the credentials to a request, if there are any
+	Authorize func(req *http.Request)
+	// Observe is called with every response, e.g. to track the quota it reports
+	Observe func(resp *http.Response)
+}
+
+// Get performs a GET request, asking for the given media type if accept isn't
+// empty. Any response other than 200 is returned as an *APIError.
+func (t *Transport) Get(url, accept string) (*http.Response, error) {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest("GET", url, nil)
+		if err != nil {
+			return nil, err
+		}
+		if t.Authorize != nil {
+			t.Authorize(req)
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			if attempt < maxRetries {
+				fmt.Printf("Request to %s failed (%v), retrying in %s...\n", t.Server, err, backoff)
+				time.Sleep(backoff)
+				backoff *= 2
+				continue
+			}
+			return nil, err
+		}
+
+		if t.Observe != nil {
+			t.Observe(resp)
+		}
+		if resp.StatusCode == http.StatusOK {
+			return resp, nil
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		apiErr := t.newAPIError(resp, body)
+
+		if attempt < maxRetries {
+			if wait, ok := retryDelay(resp, apiErr, backoff); ok {
+				fmt.Printf("%s returned %s, retrying in %s...\n", t.Server, resp.Status, wait.Round(time.Second))
+				time.Sleep(wait)
+				backoff *= 2
+				continue
+			}
+		}
+
+		return nil, apiErr
+	}
+}
+
+// retryDelay decides whether a failed response is worth retrying and how long to wait first
+func retryDelay(resp *http.Response, apiErr *APIError, backoff time.Duration) (time.Duration, bool) {
+	if resp.StatusCode >= 500 {
+		return backoff, true
+	}
+	if !errors.Is(apiErr, ErrRateLimited) {
+		return 0, false
+	}
+
+	// Secondary rate limits tell us how long to wait
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		wait := time.Duration(seconds) * time.Second
+		return wait, wait <= maxRateLimitWait
+	}
+
+	// Primary rate limits tell us when the quota resets
+	if !apiErr.ResetAt.IsZero() {
+		wait := time.Until(apiErr.ResetAt)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, wait <= maxRateLimitWait
+	}
+
+	// Rate limits that say neither should wait at least a minute
+	return time.Minute, true
+}
+
+// newAPIError builds the typed error for an unsuccessful response
+func (t *Transport) newAPIError(resp *http.Response, body []byte) *APIError {
+	apiErr := &APIError{
+		Server:     t.Server,
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       strings.TrimSpace(string(body)),
+	}
+
+	switch {
+	case isRateLimited(resp, body):
+		apiErr.err = ErrRateLimited
+		apiErr.ResetAt = rateLimitReset(resp.Header)
+	case resp.StatusCode == http.StatusNotFound:
+		apiErr.err = ErrNotFound
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		apiErr.err = ErrUnauthorized
+	}
+
+	return apiErr
+}
+
+// isRateLimited reports whether a response was rejected because of a rate
+// limit. Besides 429, GitHub answers 403 when a primary or secondary limit is hit.
+func isRateLimited(resp *http.Response, body []byte) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return resp.Header.Get("X-RateLimit-Remaining") == "0" ||
+		resp.Header.Get("Retry-After") != "" ||
+		strings.Contains(strings.ToLower(string(body)), "rate limit")
+}
+
+// rateLimitReset returns when the rate limit resets, from GitHub's
+// X-RateLimit-Reset or GitLab's RateLimit-Reset header, or the zero time
+func rateLimitReset(header http.Header) time.Time {
+	for _, name := range []string{"X-RateLimit-Reset", "RateLimit-Reset"} {
+		if reset, err := strconv.ParseInt(header.Get(name), 10, 64); err == nil {
+			return time.Unix(reset, 0)
+		}
+	}
+	return time.Time{}
+}
@@ -0,0 +1,153 @@
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches placeholders like {{ .ProjectName }}. Only simple
+// names are matched, so expressions such as Helm's {{ .Values.image }} are left alone.
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+
+// binarySniffLen is how many bytes are checked for a NUL byte to detect binary files
+const binarySniffLen = 8000
+
+// FindVariables returns the sorted names of all placeholders used in file contents
+// and file or directory names below dir
+func FindVariables(dir string) ([]string, error) {
+	found := make(map[string]bool)
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if path != dir {
+			for _, m := range placeholderPattern.FindAllStringSubmatch(d.Name(), -1) {
+				found[m[1]] = true
+			}
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isBinary(data) {
+			return nil
+		}
+		for _, m := range placeholderPattern.FindAllSubmatch(data, -1) {
+			found[string(m[1])] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan for variables: %w", err)
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Apply substitutes the given variables into file contents and into file and
+// directory names below dir. Placeholders for unknown variables are kept as is.
+func Apply(dir string, vars map[string]string) error {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if path != dir {
+			paths = append(paths, path)
+		}
+		if d.Type().IsRegular() {
+			return renderFile(path, vars)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render files: %w", err)
+	}
+
+	// Rename deepest entries first so parent paths stay valid while we go
+	sort.Slice(paths, func(i, j int) bool {
+		return strings.Count(paths[i], string(filepath.Separator)) > strings.Count(paths[j], string(filepath.Separator))
+	})
+	for _, path := range paths {
+		name := filepath.Base(path)
+		newName := String(name, vars)
+		if newName == name {
+			continue
+		}
+		if newName == "" || strings.ContainsRune(newName, filepath.Separator) {
+			return fmt.Errorf("invalid name %q rendered from %s", newName, path)
+		}
+		newPath := filepath.Join(filepath.Dir(path), newName)
+		if _, err := os.Lstat(newPath); err == nil {
+			return fmt.Errorf("cannot rename %s: %s already exists", path, newPath)
+		}
+		if err := os.Rename(path, newPath); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// String substitutes the given variables into s
+func String(s string, vars map[string]string) string {
+	return placeholderPattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return match
+	})
+}
+
+// renderFile substitutes the variables into a single text file, keeping its mode
+func renderFile(path string, vars map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if isBinary(data) || !placeholderPattern.Match(data) {
+		return nil
+	}
+
+	rendered := String(string(data), vars)
+	if rendered == string(data) {
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, []byte(rendered), info.Mode().Perm())
+}
+
+// isBinary reports whether data looks like binary content
+func isBinary(data []byte) bool {
+	if len(data) > binarySniffLen {
+		data = data[:binarySniffLen]
+	}
+	return bytes.IndexByte(data, 0) != -1
+}
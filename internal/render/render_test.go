@@ -0,0 +1,126 @@
+package render
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates the given files below dir, making parent directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the content of the slash-separated name below dir
+func readFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(name)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestFindVariables(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"README.md":                    "# {{ .ProjectName }}\n\nimage: {{ .Values.image }}\n",
+		"cmd/{{.ProjectName}}/main.go": "package main // {{.Module}}\n",
+		"{{ .Owner }}.txt":             "",
+		"logo.png":                     "\x89PNG\x00{{ .Binary }}",
+		".git/HEAD":                    "{{ .Git }}",
+	})
+
+	names, err := FindVariables(dir)
+	if err != nil {
+		t.Fatalf("FindVariables: %v", err)
+	}
+	want := []string{"Module", "Owner", "ProjectName"}
+	if !slices.Equal(names, want) {
+		t.Errorf("FindVariables = %v, want %v", names, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	dir := t.TempDir()
+	binary := "\x89PNG\x00{{ .ProjectName }}"
+	writeFiles(t, dir, map[string]string{
+		"README.md":                              "# {{ .ProjectName }}\n\nGo {{ .GoVersion }}, image {{ .Values.image }}\n",
+		"{{ .ProjectName }}/{{.ProjectName}}.go": "package {{ .ProjectName }}\n",
+		"logo.png":                               binary,
+	})
+	if err := os.Chmod(filepath.Join(dir, "README.md"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the declared variables are given, as with a manifest, so the others
+	// stay for the tools the template ships them for
+	if err := Apply(dir, map[string]string{"ProjectName": "demo"}); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	if got, want := readFile(t, dir, "README.md"), "# demo\n\nGo {{ .GoVersion }}, image {{ .Values.image }}\n"; got != want {
+		t.Errorf("README.md = %q, want %q", got, want)
+	}
+	if got := readFile(t, dir, "demo/demo.go"); got != "package demo\n" {
+		t.Errorf("demo/demo.go = %q, want the name substituted", got)
+	}
+	if got := readFile(t, dir, "logo.png"); got != binary {
+		t.Errorf("logo.png = %q, want binary files left alone", got)
+	}
+	info, err := os.Stat(filepath.Join(dir, "README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("README.md has mode %v, want it kept", info.Mode())
+	}
+}
+
+func TestApplyRefusesToOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"{{ .ProjectName }}.md": "template",
+		"demo.md":               "existing",
+	})
+
+	if err := Apply(dir, map[string]string{"ProjectName": "demo"}); err == nil {
+		t.Fatal("Apply renamed a file over an existing one")
+	}
+	if got := readFile(t, dir, "demo.md"); got != "existing" {
+		t.Errorf("demo.md = %q, want it untouched", got)
+	}
+}
+
+func TestIsBinary(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want bool
+	}{
+		{name: "text", data: []byte("hello {{ .Name }}\n"), want: false},
+		{name: "empty", data: nil, want: false},
+		{name: "NUL byte", data: []byte("PK\x03\x04\x00"), want: true},
+		// Only the start of a file is checked
+		{name: "NUL after the sniffed prefix", data: []byte(strings.Repeat("a", binarySniffLen) + "\x00"), want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isBinary(tt.data); got != tt.want {
+				t.Errorf("isBinary = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+)
+
+// dryRun merges the selected commits into a scratch worktree and prints, per
+// commit, which files would merge cleanly and which would conflict. Commits
+// after a conflicting one are checked as if its conflicts were resolved in
+// favour of the template. The project's files and metadata aren't touched.
+func dryRun(dir string, trees *upstreamTrees, commits []model.CommitInfo) error {
+	scratch, remove, err := git.ScratchWorktree(dir)
+	if err != nil {
+		return err
+	}
+	defer remove()
+
+	fmt.Println("\nDry run, the project will not be changed.")
+
+	clean := 0
+	for _, commit := range commits {
+		fmt.Printf("\n%s %s\n", commit.SHA[:8], strings.Split(commit.Message, "\n")[0])
+
+		base, target, err := trees.commitTrees(commit)
+		if err != nil {
+			return fmt.Errorf("failed to get template trees for commit %s: %w", commit.SHA, err)
+		}
+
+		paths, err := syncPaths(scratch, trees, commit, base, target)
+		if err != nil {
+			return err
+		}
+
+		result, err := git.MergeTrees(scratch, base, target, paths)
+		if err != nil {
+			return fmt.Errorf("failed to merge commit %s: %w", commit.SHA, err)
+		}
+		printMergeReport(result)
+
+		if result.Clean() {
+			clean++
+		}
+		if err := git.AcceptTarget(scratch, target, unmergedPaths(result)); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("\n%d of %d commits would apply cleanly.\n", clean, len(commits))
+	return nil
+}
+
+// printMergeReport prints the state every file touched by a merge was left in
+func printMergeReport(result *git.MergeResult) {
+	conflicted := make(map[string]bool)
+	for _, path := range result.Conflicted {
+		conflicted[path] = true
+	}
+	rejected := make(map[string]int)
+	for _, r := range result.Rejected {
+		rejected[r.Path] = r.Hunks
+	}
+
+	if len(result.Changed) == 0 {
+		fmt.Println("  no changes to the project")
+		return
+	}
+	for _, path := range result.Changed {
+		switch {
+		case conflicted[path]:
+			fmt.Printf("  conflict  %s\n", path)
+		case rejected[path] > 0:
+			fmt.Printf("  rejected  %s (%s)\n", path, pluralize(rejected[path], "hunk"))
+		default:
+			fmt.Printf("  clean     %s\n", path)
+		}
+	}
+}
+
+// unmergedPaths returns the files a merge left conflicted or rejected
+func unmergedPaths(result *git.MergeResult) []string {
+	paths := append([]string{}, result.Conflicted...)
+	for _, r := range result.Rejected {
+		paths = append(paths, r.Path)
+	}
+	return paths
+}
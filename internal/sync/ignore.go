@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"fmt"
+
+	"templatamus/internal/config"
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+)
+
+// syncPaths returns the files an upstream commit changes between the base and
+// target trees, leaving out and logging those the project's .templatamusignore
+// or the template's defaults say the project owns
+func syncPaths(dir string, trees *upstreamTrees, commit model.CommitInfo, base, target string) ([]string, error) {
+	changed, err := git.ChangedFiles(dir, base, target)
+	if err != nil {
+		return nil, err
+	}
+
+	rules, err := config.LoadIgnoreRules(dir, trees.syncIgnore(commit.SHA))
+	if err != nil {
+		return nil, err
+	}
+	if rules.Empty() {
+		return changed, nil
+	}
+
+	var paths []string
+	for _, path := range changed {
+		if rules.Match(path) {
+			fmt.Printf("  ignored   %s\n", path)
+			continue
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
@@ -0,0 +1,91 @@
+package sync
+
+import (
+	"fmt"
+	"slices"
+
+	"templatamus/internal/cli"
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+	"templatamus/internal/version"
+)
+
+// resolveSource fills in what metadata migrated from an older schema couldn't
+// record: whether the project's ref is a branch or a tag, and the commit a tag
+// that couldn't be resolved when the project was created points to. The
+// result is saved the next time the metadata is written.
+func resolveSource(src provider.SourceProvider, metadata *model.ProjectMetadata) error {
+	if metadata.RefType == "" {
+		tags, err := src.ListTags()
+		if err != nil {
+			return fmt.Errorf("failed to get tags: %w", err)
+		}
+		metadata.RefType = model.RefTypeBranch
+		if slices.Contains(tags, metadata.Ref) {
+			metadata.RefType = model.RefTypeTag
+		}
+	}
+
+	if metadata.ResolvedSHA == "" {
+		sha, err := src.ResolveRef(metadata.Ref)
+		if err != nil {
+			return fmt.Errorf("failed to resolve %s %s: %w", metadata.RefType, metadata.Ref, err)
+		}
+		metadata.ResolvedSHA = sha
+	}
+	return nil
+}
+
+// syncTag offers the tags newer than the one the project follows and upgrades
+// it to the chosen one, or to the newest with opts.All
+func syncTag(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, opts Options) error {
+	if opts.DryRun {
+		return fmt.Errorf("--dry-run only previews the commits of a branch, the project follows tag %s", metadata.Ref)
+	}
+
+	fmt.Println("Checking for newer tags...")
+	newer, err := newerTags(src, metadata.Ref)
+	if err != nil {
+		return err
+	}
+	if len(newer) == 0 {
+		fmt.Printf("Project is already up to date (no tags newer than %s found).\n", metadata.Ref)
+		return nil
+	}
+
+	fmt.Printf("Found %d tags newer than %s.\n", len(newer), metadata.Ref)
+	tag := newer[0]
+	if !opts.All {
+		tag, err = cli.Choose("Choose a tag to upgrade to", newer)
+		if err != nil {
+			return err
+		}
+	}
+	return Upgrade(dir, src, tag)
+}
+
+// newerTags returns the template's tags that are later versions than current,
+// newest first. Nothing is newer than a tag that isn't a version number.
+func newerTags(src provider.SourceProvider, current string) ([]string, error) {
+	currentVersion, ok := version.Parse(current)
+	if !ok {
+		fmt.Printf("Tag %s isn't a version number, run 'templatamus upgrade --to <tag>' to pick one.\n", current)
+		return nil, nil
+	}
+
+	tags, err := src.ListTags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tags: %w", err)
+	}
+
+	var newer []string
+	for _, tag := range tags {
+		if v, ok := version.Parse(tag); ok && v.Compare(currentVersion) > 0 {
+			newer = append(newer, tag)
+		}
+	}
+	slices.SortFunc(newer, func(a, b string) int {
+		return version.CompareTags(b, a)
+	})
+	return newer, nil
+}
@@ -0,0 +1,48 @@
+package sync
+
+import (
+	"slices"
+	"testing"
+
+	"templatamus/internal/provider"
+)
+
+// tagSource is a provider.SourceProvider that only lists tags
+type tagSource struct {
+	provider.SourceProvider
+	tags []string
+}
+
+func (s tagSource) ListTags() ([]string, error) {
+	return s.tags, nil
+}
+
+func TestNewerTags(t *testing.T) {
+	src := tagSource{tags: []string{
+		"v1.8", "v1.9", "v1.10", "latest", "v2.0.0-rc.2", "v2.0.0-rc.10", "v2.0.0", "nightly", "v2.0.0-beta",
+	}}
+
+	tests := []struct {
+		name    string
+		current string
+		want    []string
+	}{
+		{name: "numbers compare by value", current: "v1.9", want: []string{"v2.0.0", "v2.0.0-rc.10", "v2.0.0-rc.2", "v2.0.0-beta", "v1.10"}},
+		{name: "prerelease numbers compare by value", current: "v2.0.0-rc.2", want: []string{"v2.0.0", "v2.0.0-rc.10"}},
+		{name: "prerelease before release", current: "v2.0.0-beta", want: []string{"v2.0.0", "v2.0.0-rc.10", "v2.0.0-rc.2"}},
+		{name: "nothing newer than the latest release", current: "v2.0.0", want: nil},
+		{name: "nothing newer than a non-version tag", current: "latest", want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newerTags(src, tt.current)
+			if err != nil {
+				t.Fatalf("newerTags: %v", err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("newerTags(%s) = %v, want %v", tt.current, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"templatamus/internal/cli"
+	"templatamus/internal/config"
+	"templatamus/internal/git"
+)
+
+// Actions offered for each file by ResolveConflicts
+const (
+	actionUpstream = "Take upstream"
+	actionOurs     = "Keep ours"
+	actionEdit     = "Edit (git mergetool or $EDITOR)"
+	actionSkip     = "Skip the conflicting hunks"
+)
+
+// unresolvedFile is a file from the conflict patch that still needs attention
+type unresolvedFile struct {
+	path       string
+	conflicted bool
+	rejected   bool
+}
+
+// ResolveConflicts walks the user through each file the commit a sync stopped at
+// left conflicted or rejected, offering to take the upstream version, keep the
+// project's, edit the file or skip the hunks that conflict. Once no conflict
+// markers or .rej files remain the commit is marked applied; the rest of the
+// queue is left for sync --continue.
+func ResolveConflicts(dir string) error {
+	syncStatus, err := config.LoadSyncStatus(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync status: %w", err)
+	}
+	if !syncStatus.InProgress || !syncStatus.HasConflicts || syncStatus.ConflictCommit == nil {
+		return fmt.Errorf("no sync with conflicts in progress")
+	}
+
+	patch, err := config.LoadConflictPatch(dir)
+	if err != nil {
+		return err
+	}
+	paths, err := git.PatchFiles(dir, patch)
+	if err != nil {
+		return err
+	}
+
+	files, err := unresolvedFiles(dir, paths)
+	if err != nil {
+		return err
+	}
+
+	commit := syncStatus.ConflictCommit
+	fmt.Printf("Resolving commit %s: %s\n", shortSHA(commit.SHA), strings.Split(commit.Message, "\n")[0])
+
+	for _, file := range files {
+		state := "conflicted"
+		if file.rejected {
+			state = "rejected hunks"
+		}
+		action, err := cli.Choose(fmt.Sprintf("%s (%s):", file.path, state),
+			[]string{actionUpstream, actionOurs, actionEdit, actionSkip})
+		if err != nil {
+			return err
+		}
+		if err := resolveFile(dir, syncStatus.UpstreamTree, file, action); err != nil {
+			return err
+		}
+	}
+
+	if err := checkResolved(dir, paths); err != nil {
+		return err
+	}
+
+	metadata, err := config.LoadProjectMetadata(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load project metadata: %w", err)
+	}
+	if err := completeConflict(dir, metadata, syncStatus); err != nil {
+		return err
+	}
+
+	if len(syncStatus.Queue) > 0 {
+		fmt.Printf("%s left in the queue, run 'templatamus sync --continue' to apply the rest.\n", pluralize(len(syncStatus.Queue), "commit"))
+		return nil
+	}
+	if err := config.ClearSyncStatus(dir); err != nil {
+		return fmt.Errorf("failed to clear sync status: %w", err)
+	}
+	fmt.Println("Sync completed successfully.")
+	return nil
+}
+
+// checkResolved fails listing what still needs attention if any of the paths
+// of the conflict patch or any other file is left unresolved. Every file is
+// checked, so anything resolved by hand counts too.
+func checkResolved(dir string, paths []string) error {
+	remaining, err := unresolvedFiles(dir, paths)
+	if err != nil {
+		return err
+	}
+	rejects, err := git.FindRejects(dir)
+	if err != nil {
+		return err
+	}
+	if len(remaining) == 0 && len(rejects) == 0 {
+		return nil
+	}
+
+	fmt.Println("\nThese files still need attention:")
+	for _, file := range remaining {
+		fmt.Printf("  %s\n", file.path)
+	}
+	for _, rejected := range rejects {
+		fmt.Printf("  %s.rej\n", rejected.Path)
+	}
+	return fmt.Errorf("conflicts remain, run 'templatamus resolve' or fix them by hand before continuing")
+}
+
+// resolveFile applies the chosen action to a single file
+func resolveFile(dir, upstreamTree string, file unresolvedFile, action string) error {
+	switch action {
+	case actionUpstream:
+		if upstreamTree == "" {
+			return fmt.Errorf("the upstream version of %s is unknown, edit it instead", file.path)
+		}
+		return git.CheckoutFrom(dir, upstreamTree, file.path)
+
+	case actionOurs:
+		return git.CheckoutFrom(dir, "HEAD", file.path)
+
+	case actionEdit:
+		if file.conflicted {
+			if unmerged, err := git.IsUnmerged(dir, file.path); err != nil {
+				return err
+			} else if unmerged {
+				return git.MergeTool(dir, file.path)
+			}
+		}
+		paths := []string{filepath.Join(dir, filepath.FromSlash(file.path))}
+		if file.rejected {
+			paths = append(paths, paths[0]+".rej")
+		}
+		if err := cli.OpenEditor(paths...); err != nil {
+			return err
+		}
+		if file.rejected {
+			done, err := cli.Confirm(fmt.Sprintf("Have you applied the hunks from %s.rej? It will be deleted", file.path), true)
+			if err != nil || !done {
+				return err
+			}
+			if err := git.RemoveReject(dir, file.path); err != nil {
+				return err
+			}
+		}
+		// Files that still have markers are reported by the final check
+		if markers, err := git.HasConflictMarkers(dir, file.path); err != nil || markers {
+			return err
+		}
+		return git.Stage(dir, file.path)
+
+	case actionSkip:
+		if unmerged, err := git.IsUnmerged(dir, file.path); err != nil {
+			return err
+		} else if unmerged {
+			if err := git.SkipConflictingHunks(dir, file.path); err != nil {
+				return err
+			}
+		}
+		if err := git.RemoveReject(dir, file.path); err != nil {
+			return err
+		}
+		return git.Stage(dir, file.path)
+	}
+	return fmt.Errorf("unknown action %q", action)
+}
+
+// unresolvedFiles returns the files among paths that have conflict markers,
+// unmerged index entries or a .rej file
+func unresolvedFiles(dir string, paths []string) ([]unresolvedFile, error) {
+	var files []unresolvedFile
+	for _, path := range paths {
+		unmerged, err := git.IsUnmerged(dir, path)
+		if err != nil {
+			return nil, err
+		}
+		markers, err := git.HasConflictMarkers(dir, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s for conflict markers: %w", path, err)
+		}
+
+		file := unresolvedFile{path: path, conflicted: unmerged || markers, rejected: git.HasReject(dir, path)}
+		if file.conflicted || file.rejected {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
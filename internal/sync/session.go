@@ -0,0 +1,272 @@
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"templatamus/internal/config"
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+// ErrConflicts is returned when a sync stops at a commit that didn't merge cleanly
+var ErrConflicts = errors.New("merge conflicts detected, please resolve manually and run templatamus again")
+
+// startSession records the queue of commits to apply along with the project's
+// HEAD, template baseline and metadata in the sync status before any commit is
+// applied, so the sync can be resumed or undone
+func startSession(dir string, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus, commits []model.CommitInfo) error {
+	head, err := git.Head(dir)
+	if err != nil {
+		return err
+	}
+	upstream, err := git.UpstreamRefCommit(dir)
+	if err != nil {
+		return err
+	}
+
+	snapshot := *metadata
+	snapshot.AppliedCommits = append([]string{}, metadata.AppliedCommits...)
+	snapshot.SkippedCommits = append([]model.SkippedCommit{}, metadata.SkippedCommits...)
+
+	syncStatus.InProgress = true
+	syncStatus.Queue = commits
+	syncStatus.StartHead = head
+	syncStatus.StartUpstream = upstream
+	syncStatus.StartMetadata = &snapshot
+
+	if err := config.SaveSyncStatus(dir, syncStatus); err != nil {
+		return fmt.Errorf("failed to save sync status: %w", err)
+	}
+	return nil
+}
+
+// runSession applies the queued commits of a sync session and ends it once the
+// queue is empty. A new session that fails for any reason other than conflicts
+// is rolled back; a resumed one keeps the commits it already applied, so the
+// user can retry with --continue or undo everything with --abort.
+func runSession(dir string, trees *upstreamTrees, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus, resumed bool) error {
+	err := applyCommits(dir, trees, metadata, syncStatus)
+	if errors.Is(err, ErrConflicts) {
+		return err
+	}
+	if err != nil {
+		if !resumed {
+			if rbErr := rollback(dir, syncStatus); rbErr != nil {
+				return fmt.Errorf("%w (rollback failed: %v)", err, rbErr)
+			}
+			return fmt.Errorf("%w (the sync was rolled back)", err)
+		}
+
+		// Drop the half-applied commit, it stays at the front of the queue
+		if rsErr := git.ResetHard(dir, "HEAD"); rsErr != nil {
+			return fmt.Errorf("%w (reset failed: %v)", err, rsErr)
+		}
+		if rmErr := git.RemoveRejects(dir, rejectedPaths(syncStatus.RejectedFiles)); rmErr != nil {
+			return fmt.Errorf("%w (cleanup failed: %v)", err, rmErr)
+		}
+		return fmt.Errorf("%w (run 'templatamus sync --continue' to retry or 'templatamus sync --abort' to undo the sync)", err)
+	}
+
+	if err := config.ClearSyncStatus(dir); err != nil {
+		return fmt.Errorf("failed to clear sync status: %w", err)
+	}
+	fmt.Println("Sync completed successfully.")
+	return nil
+}
+
+// resumeSession applies what is left of the queue of the sync in progress
+func resumeSession(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+	trees, err := newUpstreamTrees(dir, src, metadata.Variables)
+	if err != nil {
+		return err
+	}
+	if len(syncStatus.Queue) > 0 {
+		fmt.Printf("Resuming sync with %s in the queue\n", pluralize(len(syncStatus.Queue), "commit"))
+	}
+	return runSession(dir, trees, metadata, syncStatus, true)
+}
+
+// continueSync resumes the sync in progress in dir, like git rebase --continue:
+// the commit it stopped at is committed once its conflicts are resolved and the
+// rest of the queue is applied
+func continueSync(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+	if syncStatus.HasConflicts {
+		if syncStatus.ConflictCommit == nil {
+			return fmt.Errorf("missing conflict commit information")
+		}
+		patch, err := config.LoadConflictPatch(dir)
+		if err != nil {
+			return err
+		}
+		paths, err := git.PatchFiles(dir, patch)
+		if err != nil {
+			return err
+		}
+		if err := checkResolved(dir, paths); err != nil {
+			return err
+		}
+		if err := completeConflict(dir, metadata, syncStatus); err != nil {
+			return err
+		}
+	} else {
+		hasChanges, err := git.CheckRepoStatus(dir, config.SyncStateFiles()...)
+		if err != nil {
+			return fmt.Errorf("failed to check repository status: %w", err)
+		}
+		if hasChanges {
+			return fmt.Errorf("the interrupted sync left uncommitted changes, discard them with 'git reset --hard' or run 'templatamus sync --abort'")
+		}
+	}
+
+	return resumeSession(dir, src, metadata, syncStatus)
+}
+
+// skipCommit drops the commit the sync in progress stopped at, like git rebase
+// --skip, and resumes with the next queued commit. git.UpstreamRef keeps
+// recording the template's full trees, so once a later commit is applied the
+// skipped change shows up in git diff UpstreamRef HEAD as a local difference.
+func skipCommit(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+	if !syncStatus.HasConflicts || syncStatus.ConflictCommit == nil {
+		return fmt.Errorf("the sync didn't stop at a conflict, there is no commit to skip")
+	}
+
+	if err := git.ResetHard(dir, "HEAD"); err != nil {
+		return err
+	}
+	if err := git.RemoveRejects(dir, rejectedPaths(syncStatus.RejectedFiles)); err != nil {
+		return err
+	}
+	if err := config.RemoveConflictPatch(dir); err != nil {
+		return err
+	}
+
+	// Record the skip, so later syncs don't offer the commit again unasked
+	commit := *syncStatus.ConflictCommit
+	metadata.MarkSkipped(commit.SHA, skipReason(syncStatus))
+	if err := config.SaveProjectMetadata(dir, metadata); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+	commitMsg := fmt.Sprintf("Skipped %s: %s", metadata.SourceRepo, strings.Split(commit.Message, "\n")[0])
+	if err := git.CommitChanges(dir, commitMsg, config.SyncStateFiles()...); err != nil {
+		return fmt.Errorf("failed to commit metadata: %w", err)
+	}
+	fmt.Printf("Skipped commit %s\n", shortSHA(commit.SHA))
+	if len(syncStatus.Queue) > 0 {
+		fmt.Printf("Its change is part of the template trees recorded for the commits after it, so 'git diff %s HEAD' lists it as a local difference.\n", git.UpstreamRef)
+	}
+
+	clearConflict(syncStatus)
+	if err := config.SaveSyncStatus(dir, syncStatus); err != nil {
+		return fmt.Errorf("failed to save sync status: %w", err)
+	}
+	return resumeSession(dir, src, metadata, syncStatus)
+}
+
+// appliedInSession reports whether a commit was applied since the sync session started
+func appliedInSession(metadata *model.ProjectMetadata, syncStatus *model.SyncStatus, sha string) bool {
+	if !slices.Contains(metadata.AppliedCommits, sha) {
+		return false
+	}
+	return syncStatus.StartMetadata == nil || !slices.Contains(syncStatus.StartMetadata.AppliedCommits, sha)
+}
+
+// rejectedPaths returns the paths of the files whose changes were rejected
+func rejectedPaths(files []model.RejectedFile) []string {
+	paths := make([]string, len(files))
+	for i, rejected := range files {
+		paths[i] = rejected.Path
+	}
+	return paths
+}
+
+// skipReason describes the conflicts a commit was skipped for
+func skipReason(syncStatus *model.SyncStatus) string {
+	paths := append([]string{}, syncStatus.ConflictedFiles...)
+	for _, rejected := range syncStatus.RejectedFiles {
+		paths = append(paths, rejected.Path)
+	}
+	if len(paths) == 0 {
+		return "skipped during sync"
+	}
+	return "conflicts in " + strings.Join(paths, ", ")
+}
+
+// clearConflict resets the conflict fields of a sync status, keeping the session
+func clearConflict(syncStatus *model.SyncStatus) {
+	syncStatus.CurrentCommit = ""
+	syncStatus.HasConflicts = false
+	syncStatus.ConflictsAt = time.Time{}
+	syncStatus.ConflictCommit = nil
+	syncStatus.ConflictedFiles = nil
+	syncStatus.RejectedFiles = nil
+	syncStatus.UpstreamTree = ""
+}
+
+// rollback puts the project back where its sync session started and ends the session
+func rollback(dir string, syncStatus *model.SyncStatus) error {
+	if err := git.ResetHard(dir, syncStatus.StartHead); err != nil {
+		return err
+	}
+	if err := git.RemoveRejects(dir, rejectedPaths(syncStatus.RejectedFiles)); err != nil {
+		return err
+	}
+	if err := git.RestoreUpstreamRef(dir, syncStatus.StartUpstream); err != nil {
+		return err
+	}
+
+	// The metadata is committed with each synced commit, so the reset restored
+	// it as it was, possibly in an older schema. Restore it only if it isn't tracked.
+	tracked, err := git.IsTracked(dir, config.MetadataFile())
+	if err != nil {
+		return err
+	}
+	if syncStatus.StartMetadata != nil && !tracked {
+		if err := config.SaveProjectMetadata(dir, syncStatus.StartMetadata); err != nil {
+			return fmt.Errorf("failed to restore metadata: %w", err)
+		}
+	}
+
+	if err := config.RemoveConflictPatch(dir); err != nil {
+		return err
+	}
+	if err := config.ClearSyncStatus(dir); err != nil {
+		return fmt.Errorf("failed to clear sync status: %w", err)
+	}
+	return nil
+}
+
+// AbortSync undoes the sync in progress in dir, like git rebase --abort: the
+// commits it made, its conflicts and any metadata changes are thrown away
+func AbortSync(dir string) error {
+	syncStatus, err := config.LoadSyncStatus(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync status: %w", err)
+	}
+	if !syncStatus.InProgress {
+		return fmt.Errorf("no sync in progress")
+	}
+
+	// Sync statuses written before sessions were recorded only know the conflict
+	if syncStatus.StartHead == "" {
+		head, err := git.Head(dir)
+		if err != nil {
+			return err
+		}
+		syncStatus.StartHead = head
+		syncStatus.StartUpstream, err = git.UpstreamRefCommit(dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := rollback(dir, syncStatus); err != nil {
+		return err
+	}
+	fmt.Printf("Sync aborted, the project is back at %s.\n", shortSHA(syncStatus.StartHead))
+	return nil
+}
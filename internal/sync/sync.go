@@ -1,18 +1,22 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
-	"sort"
 	"strings"
 	"time"
 
 	"templatamus/internal/cli"
 	"templatamus/internal/config"
 	"templatamus/internal/git"
-	"templatamus/internal/github"
 	"templatamus/internal/model"
+	"templatamus/internal/provider"
+	"templatamus/internal/render"
 )
 
 // DetectProject checks if the current directory or specified directory is a templatamus project
@@ -54,18 +58,31 @@ func DetectProject() (string, bool, error) {
 	return target, false, nil
 }
 
+// Options controls how SyncProject behaves
+type Options struct {
+	// All applies every pending commit without prompting for a selection
+	All bool
+	// DryRun reports how the selected commits would merge without changing the project
+	DryRun bool
+	// Continue resumes the sync in progress once its conflicts are resolved
+	Continue bool
+	// Skip drops the commit the sync in progress stopped at and resumes with the next one
+	Skip bool
+	// IncludeSkipped offers the commits that were skipped before again
+	IncludeSkipped bool
+}
+
 // SyncProject synchronizes a project with its source repository
-func SyncProject(dir string, ghClient *github.Client) error {
+func SyncProject(dir string, src provider.SourceProvider, opts Options) error {
 	// Load metadata
 	metadata, err := config.LoadProjectMetadata(dir)
 	if err != nil {
 		return fmt.Errorf("failed to load project metadata: %w", err)
 	}
 
-	// Debug: Show current metadata
-	fmt.Printf("Project metadata: source=%s, branch=%s\n", metadata.SourceRepo, metadata.SourceBranch)
-	fmt.Printf("Original source commit: %s\n", metadata.SourceCommit[:8])
-	fmt.Printf("Applied commits: %d\n", len(metadata.AppliedCommits))
+	if err := resolveSource(src, metadata); err != nil {
+		return err
+	}
 
 	// Check if there's a sync in progress
 	syncStatus, err := config.LoadSyncStatus(dir)
@@ -73,68 +90,48 @@ func SyncProject(dir string, ghClient *github.Client) error {
 		return fmt.Errorf("failed to load sync status: %w", err)
 	}
 
-	// If there's a sync in progress with conflicts, handle it
-	if syncStatus.InProgress && syncStatus.HasConflicts {
-		return handleConflictResolution(dir, metadata, syncStatus)
+	// Pick up a sync that stopped at conflicts or was interrupted
+	if syncStatus.InProgress {
+		switch {
+		case opts.DryRun:
+			return fmt.Errorf("a sync is in progress, finish it with --continue or --abort before a dry run")
+		case opts.Continue:
+			return continueSync(dir, src, metadata, syncStatus)
+		case opts.Skip:
+			return skipCommit(dir, src, metadata, syncStatus)
+		case syncStatus.HasConflicts:
+			return handleConflictResolution(dir, src, metadata, syncStatus)
+		default:
+			return fmt.Errorf("a sync with %s left is in progress, run 'templatamus sync --continue' to resume it or 'templatamus sync --abort' to undo it",
+				pluralize(len(syncStatus.Queue), "commit"))
+		}
+	}
+	if opts.Continue || opts.Skip {
+		return fmt.Errorf("no sync in progress")
 	}
 
-	// Split repo into owner/name
-	parts := strings.Split(metadata.SourceRepo, "/")
-	if len(parts) != 2 {
-		return fmt.Errorf("invalid repository format: %s", metadata.SourceRepo)
+	// Only projects following a branch take upstream commits one by one
+	switch metadata.RefType {
+	case model.RefTypeTag:
+		return syncTag(dir, src, metadata, opts)
+	case model.RefTypeCommit:
+		fmt.Printf("Project is pinned to commit %s, run 'templatamus upgrade --to <tag>' to move it.\n", shortSHA(metadata.Ref))
+		return nil
 	}
-	owner, repo := parts[0], parts[1]
 
-	// Get all commits from the branch
+	// Get the commits that haven't been applied yet
 	fmt.Println("Checking for updates...")
-	commits, err := ghClient.GetCommits(owner, repo, metadata.SourceBranch, time.Time{}) // Get all commits
+	pending, err := findPendingCommits(dir, src, metadata, opts.IncludeSkipped)
 	if err != nil {
-		return fmt.Errorf("failed to get commits: %w", err)
+		return err
 	}
-
-	fmt.Printf("Found %d commits from GitHub\n", len(commits))
-
-	// Create a map of applied commits for quick lookup
-	appliedSet := make(map[string]bool)
-	for _, sha := range metadata.AppliedCommits {
-		appliedSet[sha] = true
+	for _, merge := range pending.merges {
+		fmt.Printf("Skipping merge commit %s\n", merge.SHA[:8])
 	}
-
-	// Filter for only new commits that haven't been applied
-	sourceCommitFound := false
-	var newCommits []model.CommitInfo
-	
-	// First, sort commits by date (oldest first)
-	sort.Slice(commits, func(i, j int) bool {
-		return commits[i].Date.Before(commits[j].Date)
-	})
-	
-	// Find the index of the source commit
-	sourceCommitIndex := -1
-	for i, commit := range commits {
-		if commit.SHA == metadata.SourceCommit {
-			sourceCommitIndex = i
-			sourceCommitFound = true
-			break
-		}
-	}
-	
-	if !sourceCommitFound {
-		fmt.Printf("Warning: Source commit %s not found in commit history.\n", metadata.SourceCommit[:8])
-		// If we can't find the source commit, we'll include all commits that haven't been applied
-		for _, commit := range commits {
-			if !appliedSet[commit.SHA] {
-				newCommits = append(newCommits, commit)
-			}
-		}
-	} else {
-		// Include only commits that come after the source commit
-		for i := sourceCommitIndex + 1; i < len(commits); i++ {
-			commit := commits[i]
-			if !appliedSet[commit.SHA] {
-				newCommits = append(newCommits, commit)
-			}
-		}
+	newCommits := pending.commits
+	if pending.hidden > 0 {
+		fmt.Printf("Hiding %s skipped before, run with --include-skipped to offer them again.\n",
+			pluralize(pending.hidden, "commit"))
 	}
 
 	if len(newCommits) == 0 {
@@ -144,18 +141,15 @@ func SyncProject(dir string, ghClient *github.Client) error {
 
 	fmt.Printf("Found %d new commits that haven't been applied.\n", len(newCommits))
 
-	// Sort commits by date (oldest first)
-	sort.Slice(newCommits, func(i, j int) bool {
-		return newCommits[i].Date.Before(newCommits[j].Date)
-	})
-
 	// Check for existing changes before proceeding
-	hasChanges, err := git.CheckRepoStatus(dir)
+	hasChanges, err := git.CheckRepoStatus(dir, config.SyncStateFiles()...)
 	if err != nil {
 		return fmt.Errorf("failed to check repository status: %w", err)
 	}
 
-	if hasChanges {
+	if hasChanges && opts.DryRun {
+		fmt.Println("Warning: uncommitted changes are not included in the dry run, it starts from HEAD.")
+	} else if hasChanges {
 		fmt.Println("\nError: You have uncommitted changes or untracked files in your working directory.")
 		fmt.Println("Please either:")
 		fmt.Println("1. Commit your changes: git add . && git commit -m 'your message'")
@@ -164,10 +158,13 @@ func SyncProject(dir string, ghClient *github.Client) error {
 		return fmt.Errorf("working directory is not clean")
 	}
 
-	// Let user select which commits to apply
-	selectedCommits, err := cli.ChooseCommits(newCommits)
-	if err != nil {
-		return fmt.Errorf("commit selection failed: %w", err)
+	// Let user select which commits to apply, unless all of them were requested
+	selectedCommits := newCommits
+	if !opts.All {
+		selectedCommits, err = cli.ChooseCommits(newCommits)
+		if err != nil {
+			return fmt.Errorf("commit selection failed: %w", err)
+		}
 	}
 
 	if len(selectedCommits) == 0 {
@@ -175,45 +172,88 @@ func SyncProject(dir string, ghClient *github.Client) error {
 		return nil
 	}
 
-	// Apply each selected commit
-	for _, commit := range selectedCommits {
-		// Double-check it's not already applied
-		if appliedSet[commit.SHA] {
-			fmt.Printf("Skipping already applied commit: %s\n", commit.SHA[:8])
+	trees, err := newUpstreamTrees(dir, src, metadata.Variables)
+	if err != nil {
+		return err
+	}
+
+	if opts.DryRun {
+		return dryRun(dir, trees, selectedCommits)
+	}
+
+	// Record where the session started, so a failure can put everything back
+	if err := startSession(dir, metadata, syncStatus, selectedCommits); err != nil {
+		return err
+	}
+
+	// The next sync starts after the last applied commit, so the ones passed
+	// over before it are recorded as skipped to be offered by --include-skipped
+	if markPassedOver(metadata, newCommits, selectedCommits) {
+		if err := config.SaveProjectMetadata(dir, metadata); err != nil {
+			if rbErr := rollback(dir, syncStatus); rbErr != nil {
+				return fmt.Errorf("failed to update metadata: %w (rollback failed: %v)", err, rbErr)
+			}
+			return fmt.Errorf("failed to update metadata: %w", err)
+		}
+	}
+	return runSession(dir, trees, metadata, syncStatus, false)
+}
+
+// applyCommits merges and commits each queued commit with a three-way merge
+// between the template trees before and after it and the project's tree,
+// saving the queue after each one. It stops with ErrConflicts at the first
+// commit that doesn't merge cleanly.
+func applyCommits(dir string, trees *upstreamTrees, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+	for len(syncStatus.Queue) > 0 {
+		commit := syncStatus.Queue[0]
+
+		// An interrupted sync may have committed it before the queue was saved
+		if appliedInSession(metadata, syncStatus, commit.SHA) {
+			syncStatus.Queue = syncStatus.Queue[1:]
 			continue
 		}
 
 		fmt.Printf("Applying commit: %s - %s\n", commit.SHA[:8], strings.Split(commit.Message, "\n")[0])
 
-		// Get the diff
-		diff, err := ghClient.GetDiff(owner, repo, commit.SHA)
+		base, target, err := trees.commitTrees(commit)
 		if err != nil {
-			return fmt.Errorf("failed to get diff for commit %s: %w", commit.SHA, err)
+			return fmt.Errorf("failed to get template trees for commit %s: %w", commit.SHA, err)
 		}
 
-		// Apply the diff
-		success, err := git.ApplyDiff(dir, diff)
+		paths, err := syncPaths(dir, trees, commit, base, target)
 		if err != nil {
-			return fmt.Errorf("failed to apply diff: %w", err)
+			return err
 		}
 
-		if !success {
-			// Save the patch file
-			patchPath := filepath.Join(dir, ".templatamus", "conflict.patch")
-			if err := os.MkdirAll(filepath.Dir(patchPath), 0755); err != nil {
-				return fmt.Errorf("failed to create .templatamus directory: %w", err)
-			}
-			if err := os.WriteFile(patchPath, []byte(diff), 0644); err != nil {
-				return fmt.Errorf("failed to save patch file: %w", err)
-			}
+		// Merge the changes
+		result, err := git.MergeTrees(dir, base, target, paths)
+		if err != nil {
+			return fmt.Errorf("failed to merge commit %s: %w", commit.SHA, err)
+		}
 
-			// Save the conflict status
+		if !result.Clean() {
+			// Record the conflict first, so the .rej files are cleaned up
+			// if saving it fails
+			syncStatus.Queue = syncStatus.Queue[1:]
 			syncStatus.InProgress = true
 			syncStatus.CurrentCommit = commit.SHA
 			syncStatus.HasConflicts = true
 			syncStatus.ConflictsAt = time.Now()
 			syncStatus.ConflictCommit = &commit
+			syncStatus.ConflictedFiles = result.Conflicted
+			syncStatus.RejectedFiles = result.Rejected
+			syncStatus.UpstreamTree = target
+
+			// Save the patch file
+			diff, err := git.DiffTrees(dir, base, target, paths...)
+			if err != nil {
+				return err
+			}
+			if err := config.SaveConflictPatch(dir, diff); err != nil {
+				return err
+			}
 
+			// Save the conflict status
 			if err := config.SaveSyncStatus(dir, syncStatus); err != nil {
 				return fmt.Errorf("failed to save sync status: %w", err)
 			}
@@ -223,43 +263,25 @@ func SyncProject(dir string, ghClient *github.Client) error {
 			fmt.Printf("Commit message: %s\n", strings.Split(commit.Message, "\n")[0])
 			fmt.Printf("Author: %s\n", commit.Author)
 			fmt.Printf("Date: %s\n\n", commit.Date.Format(time.RFC3339))
-			
-			fmt.Println("To resolve the conflicts:")
+			printConflictedFiles(result)
+
+			fmt.Println("Run 'templatamus resolve' to go through the files one by one, or to resolve the conflicts by hand:")
 			fmt.Println("1. The patch file has been saved to .templatamus/conflict.patch")
-			fmt.Println("2. Review the conflicts in your working directory")
-			fmt.Println("3. Resolve the conflicts manually")
-			fmt.Println("4. Stage and commit your changes")
-			fmt.Println("5. Run 'templatamus' again to continue the sync")
-			fmt.Println("\nOr if you want to skip this commit:")
-			fmt.Println("1. Run 'git reset --hard HEAD' to discard changes")
-			fmt.Println("2. Run 'templatamus' again to continue with the next commit")
-			
-			return fmt.Errorf("merge conflicts detected, please resolve manually and run templatamus again")
-		}
-
-		// Clean up any .rej files that might have been created
-		rejFiles, err := filepath.Glob(filepath.Join(dir, "*.rej"))
-		if err != nil {
-			return fmt.Errorf("failed to check for .rej files: %w", err)
-		}
-		for _, rejFile := range rejFiles {
-			if err := os.Remove(rejFile); err != nil {
-				return fmt.Errorf("failed to remove .rej file %s: %w", rejFile, err)
+			fmt.Println("2. Resolve the conflict markers in the files above, e.g. with 'git mergetool'")
+			fmt.Println("3. Apply the changes in any .rej files by hand and delete them")
+			fmt.Println("4. Stage your changes with 'git add'")
+			fmt.Println("5. Run 'templatamus sync --continue' to commit it and apply the rest of the queue")
+			fmt.Println("\nOr run 'templatamus sync --skip' to drop this commit and continue with the next one,")
+			fmt.Println("or 'templatamus sync --abort' to undo the whole sync.")
+			if len(syncStatus.Queue) > 0 {
+				fmt.Printf("\n%s left in the queue after this one.\n", pluralize(len(syncStatus.Queue), "commit"))
 			}
-		}
-
-		// Clean up the patch file and sync status BEFORE committing
-		patchPath := filepath.Join(dir, ".templatamus", "conflict.patch")
-		if err := os.Remove(patchPath); err != nil && !os.IsNotExist(err) {
-			return fmt.Errorf("failed to remove patch file: %w", err)
-		}
 
-		if err := config.ClearSyncStatus(dir); err != nil {
-			return fmt.Errorf("failed to clear sync status: %w", err)
+			return ErrConflicts
 		}
 
 		// Update metadata BEFORE committing
-		metadata.AppliedCommits = append(metadata.AppliedCommits, commit.SHA)
+		metadata.MarkApplied(commit.SHA)
 		metadata.LastSyncedAt = time.Now()
 
 		if err := config.SaveProjectMetadata(dir, metadata); err != nil {
@@ -268,26 +290,283 @@ func SyncProject(dir string, ghClient *github.Client) error {
 
 		// Now commit the resolved changes
 		commitMsg := fmt.Sprintf("Synced with %s: %s (resolved conflicts)", metadata.SourceRepo, strings.Split(commit.Message, "\n")[0])
-		if err := git.CommitChanges(dir, commitMsg); err != nil {
+		if err := git.CommitChanges(dir, commitMsg, config.SyncStateFiles()...); err != nil {
 			return fmt.Errorf("failed to commit resolved changes: %w", err)
 		}
 
+		if err := recordUpstream(dir, metadata, commit, target); err != nil {
+			return err
+		}
+
+		syncStatus.Queue = syncStatus.Queue[1:]
+		if err := config.SaveSyncStatus(dir, syncStatus); err != nil {
+			return fmt.Errorf("failed to save sync status: %w", err)
+		}
+
 		fmt.Printf("Successfully applied commit %s with resolved conflicts.\n", commit.SHA[:8])
 	}
 
-	fmt.Println("Sync completed successfully.")
 	return nil
 }
 
+// PrintStatus shows the project metadata, any sync in progress and the number of pending commits
+func PrintStatus(dir string, src provider.SourceProvider) error {
+	metadata, err := config.LoadProjectMetadata(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load project metadata: %w", err)
+	}
+
+	syncStatus, err := config.LoadSyncStatus(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync status: %w", err)
+	}
+	if err := resolveSource(src, metadata); err != nil {
+		return err
+	}
+
+	fmt.Printf("Project:         %s\n", dir)
+	fmt.Printf("Source repo:     %s\n", metadata.SourceRepo)
+	fmt.Printf("Source ref:      %s %s\n", metadata.RefType, metadata.Ref)
+	fmt.Printf("Source commit:   %s\n", metadata.ResolvedSHA)
+	fmt.Printf("Created at:      %s\n", metadata.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Last synced at:  %s\n", metadata.LastSyncedAt.Format(time.RFC3339))
+	fmt.Printf("Applied commits: %d\n", len(metadata.AppliedCommits))
+	if len(metadata.SkippedCommits) > 0 {
+		fmt.Printf("Skipped commits: %d\n", len(metadata.SkippedCommits))
+		for _, skipped := range metadata.SkippedCommits {
+			fmt.Printf("  %s on %s: %s\n", shortSHA(skipped.SHA), skipped.SkippedAt.Format(time.RFC3339), skipped.Reason)
+		}
+	}
+
+	if syncStatus.InProgress && syncStatus.HasConflicts {
+		fmt.Printf("Sync status:     conflicts in commit %s since %s\n",
+			syncStatus.CurrentCommit, syncStatus.ConflictsAt.Format(time.RFC3339))
+		for _, path := range syncStatus.ConflictedFiles {
+			fmt.Printf("  conflicted: %s\n", path)
+		}
+		for _, rejected := range syncStatus.RejectedFiles {
+			fmt.Printf("  rejected:   %s (%s)\n", rejected.Path, pluralize(rejected.Hunks, "hunk"))
+		}
+	} else if syncStatus.InProgress {
+		fmt.Println("Sync status:     interrupted, run 'templatamus sync --continue' or 'templatamus sync --abort'")
+	}
+	if syncStatus.InProgress {
+		fmt.Printf("Queued commits:  %d\n", len(syncStatus.Queue))
+		for _, commit := range syncStatus.Queue {
+			fmt.Printf("  %s %s\n", shortSHA(commit.SHA), strings.Split(commit.Message, "\n")[0])
+		}
+		return nil
+	}
+
+	switch metadata.RefType {
+	case model.RefTypeTag:
+		newer, err := newerTags(src, metadata.Ref)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Newer tags:      %d\n", len(newer))
+		for _, tag := range newer {
+			fmt.Printf("  %s\n", tag)
+		}
+	case model.RefTypeCommit:
+		fmt.Println("Pending commits: none, the project is pinned")
+	default:
+		pending, err := findPendingCommits(dir, src, metadata, false)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Pending commits: %d\n", len(pending.commits))
+		for _, commit := range pending.commits {
+			fmt.Printf("  %s %s\n", commit.SHA[:8], strings.Split(commit.Message, "\n")[0])
+		}
+	}
+
+	if reporter, ok := src.(provider.QuotaReporter); ok {
+		if quota := reporter.Quota(); quota != "" {
+			fmt.Printf("API quota:       %s\n", quota)
+		}
+	}
+
+	return nil
+}
+
+// pendingCommits is what findPendingCommits found on the source branch
+type pendingCommits struct {
+	// commits are the commits to offer, in topological order
+	commits []model.CommitInfo
+	// merges are the merge commits left out, as their changes come in through
+	// the commits of the merged branch, which are listed on their own
+	merges []model.CommitInfo
+	// hidden counts the skipped commits on the branch that were left out
+	hidden int
+}
+
+// findPendingCommits returns the upstream commits between the last synced commit
+// and the head of the source branch that haven't been applied yet, in
+// topological order. Skipped commits are left out unless includeSkipped is set.
+func findPendingCommits(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, includeSkipped bool) (*pendingCommits, error) {
+	base, err := lastSyncedCommit(dir, metadata)
+	if err != nil {
+		return nil, err
+	}
+	comparison, err := src.ListCommits(base, metadata.Ref)
+	if errors.Is(err, provider.ErrNotFound) {
+		return nil, fmt.Errorf("last synced commit %s or branch %s no longer exists in %s: %w",
+			shortSHA(base), metadata.Ref, metadata.SourceRepo, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare %s with %s: %w", shortSHA(base), metadata.Ref, err)
+	}
+
+	// Diverged means the last synced commit is no longer in the branch's
+	// history, usually because upstream was rebased or force-pushed
+	if comparison.Status == "diverged" {
+		fmt.Printf("Warning: %s has diverged from last synced commit %s (%d commits no longer on the branch).\n",
+			metadata.Ref, shortSHA(base), comparison.BehindBy)
+	}
+
+	// Create a map of applied commits for quick lookup
+	appliedSet := make(map[string]bool)
+	for _, sha := range metadata.AppliedCommits {
+		appliedSet[sha] = true
+	}
+
+	pending := &pendingCommits{}
+	listed := make(map[string]bool)
+	for _, commit := range comparison.Commits {
+		listed[commit.SHA] = true
+		if appliedSet[commit.SHA] {
+			continue
+		}
+		if commit.IsMerge() {
+			pending.merges = append(pending.merges, commit)
+			continue
+		}
+		if skipped, ok := metadata.FindSkipped(commit.SHA); ok {
+			if !includeSkipped {
+				pending.hidden++
+				continue
+			}
+			commit.SkipReason = skipped.Reason
+		}
+		pending.commits = append(pending.commits, commit)
+	}
+
+	if !includeSkipped {
+		return pending, nil
+	}
+
+	// Skipped commits that are no longer on the branch, e.g. after upstream was
+	// force-pushed, are looked up on their own and offered first
+	var earlier []model.CommitInfo
+	for _, skipped := range metadata.SkippedCommits {
+		if listed[skipped.SHA] || appliedSet[skipped.SHA] {
+			continue
+		}
+		commit, err := src.GetCommit(skipped.SHA)
+		if errors.Is(err, provider.ErrNotFound) {
+			fmt.Printf("Warning: skipped commit %s no longer exists in %s.\n", shortSHA(skipped.SHA), metadata.SourceRepo)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get skipped commit %s: %w", shortSHA(skipped.SHA), err)
+		}
+		commit.SkipReason = skipped.Reason
+		earlier = append(earlier, *commit)
+	}
+
+	pending.commits = append(earlier, pending.commits...)
+	return pending, nil
+}
+
+// lastSyncedCommit returns the template commit the project was last synced
+// from: the one recorded in git.UpstreamRef, or else the last applied commit,
+// or the commit its ref resolved to for projects that have neither
+func lastSyncedCommit(dir string, metadata *model.ProjectMetadata) (string, error) {
+	sha, _, err := git.Upstream(dir)
+	if err != nil {
+		return "", err
+	}
+	if sha != "" {
+		return sha, nil
+	}
+	if n := len(metadata.AppliedCommits); n > 0 {
+		return metadata.AppliedCommits[n-1], nil
+	}
+	return metadata.ResolvedSHA, nil
+}
+
+// markPassedOver records the offered commits that weren't selected but come
+// before the last selected one as skipped, reporting whether there were any
+func markPassedOver(metadata *model.ProjectMetadata, offered, selected []model.CommitInfo) bool {
+	if len(selected) == 0 {
+		return false
+	}
+	last := selected[len(selected)-1].SHA
+	chosen := make(map[string]bool)
+	for _, commit := range selected {
+		chosen[commit.SHA] = true
+	}
+
+	marked := false
+	for _, commit := range offered {
+		if commit.SHA == last {
+			break
+		}
+		if !chosen[commit.SHA] && commit.SkipReason == "" {
+			metadata.MarkSkipped(commit.SHA, "not selected")
+			marked = true
+		}
+	}
+	return marked
+}
+
+// recordUpstream moves git.UpstreamRef to the template tree of an applied commit
+func recordUpstream(dir string, metadata *model.ProjectMetadata, commit model.CommitInfo, tree string) error {
+	message := upstreamMessage(metadata.SourceRepo, commit.SHA, strings.Split(commit.Message, "\n")[0])
+	if err := git.RecordUpstream(dir, tree, commit.SHA, message); err != nil {
+		return fmt.Errorf("failed to record template tree: %w", err)
+	}
+	return nil
+}
+
+// printConflictedFiles lists the files a merge left conflicts in
+func printConflictedFiles(result *git.MergeResult) {
+	if len(result.Conflicted) > 0 {
+		fmt.Println("Files with conflict markers:")
+		for _, path := range result.Conflicted {
+			fmt.Printf("  %s\n", path)
+		}
+	}
+	if len(result.Rejected) > 0 {
+		fmt.Println("Files with changes that couldn't be merged (see the .rej files):")
+		for _, rejected := range result.Rejected {
+			fmt.Printf("  %s (%s)\n", rejected.Path, pluralize(rejected.Hunks, "hunk"))
+		}
+	}
+	fmt.Println()
+}
+
+// pluralize formats a count with a noun, adding an s unless the count is one
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
 // handleConflictResolution handles resolving conflicts from a previous sync
-func handleConflictResolution(dir string, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+func handleConflictResolution(dir string, src provider.SourceProvider, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
 	if syncStatus.ConflictCommit == nil {
 		return fmt.Errorf("missing conflict commit information")
 	}
 
 	commit := *syncStatus.ConflictCommit
 	fmt.Printf("Detected a previous sync with conflicts for commit %s\n", commit.SHA[:8])
-	
+	if len(syncStatus.Queue) > 0 {
+		fmt.Printf("%s left in the queue after it.\n", pluralize(len(syncStatus.Queue), "commit"))
+	}
+
 	// Check if they want to consider the conflict resolved
 	resolved, err := cli.Confirm("Have you resolved the conflicts and want to continue?", true)
 	if err != nil {
@@ -302,58 +581,32 @@ func handleConflictResolution(dir string, metadata *model.ProjectMetadata, syncS
 		}
 
 		if abort {
-			// Clean up all temporary files
-			patchPath := filepath.Join(dir, ".templatamus", "conflict.patch")
-			if err := os.Remove(patchPath); err != nil && !os.IsNotExist(err) {
-				return fmt.Errorf("failed to remove patch file: %w", err)
-			}
-
-			// Clean up any .rej files
-			rejFiles, err := filepath.Glob(filepath.Join(dir, "*.rej"))
-			if err != nil {
-				return fmt.Errorf("failed to check for .rej files: %w", err)
-			}
-			for _, rejFile := range rejFiles {
-				if err := os.Remove(rejFile); err != nil {
-					return fmt.Errorf("failed to remove .rej file %s: %w", rejFile, err)
-				}
-			}
-
-			// Clear the sync status
-			if err := config.ClearSyncStatus(dir); err != nil {
-				return fmt.Errorf("failed to clear sync status: %w", err)
-			}
-
-			fmt.Printf("Skipped commit %s due to unresolved conflicts.\n", commit.SHA[:8])
-			return nil
+			return skipCommit(dir, src, metadata, syncStatus)
 		}
 
 		return fmt.Errorf("sync aborted, please resolve conflicts and try again")
 	}
 
-	// Clean up any .rej files that might have been created
-	rejFiles, err := filepath.Glob(filepath.Join(dir, "*.rej"))
-	if err != nil {
-		return fmt.Errorf("failed to check for .rej files: %w", err)
-	}
-	for _, rejFile := range rejFiles {
-		if err := os.Remove(rejFile); err != nil {
-			return fmt.Errorf("failed to remove .rej file %s: %w", rejFile, err)
-		}
-	}
+	return continueSync(dir, src, metadata, syncStatus)
+}
 
-	// Clean up the patch file and sync status BEFORE committing
-	patchPath := filepath.Join(dir, ".templatamus", "conflict.patch")
-	if err := os.Remove(patchPath); err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to remove patch file: %w", err)
+// completeConflict commits the resolved changes of the commit a sync stopped at
+// and marks it applied
+func completeConflict(dir string, metadata *model.ProjectMetadata, syncStatus *model.SyncStatus) error {
+	commit := *syncStatus.ConflictCommit
+
+	// Clean up the .rej files the merge created, if they are still there
+	if err := git.RemoveRejects(dir, rejectedPaths(syncStatus.RejectedFiles)); err != nil {
+		return err
 	}
 
-	if err := config.ClearSyncStatus(dir); err != nil {
-		return fmt.Errorf("failed to clear sync status: %w", err)
+	// Clean up the patch file and sync status BEFORE committing
+	if err := config.RemoveConflictPatch(dir); err != nil {
+		return err
 	}
 
 	// Update metadata BEFORE committing
-	metadata.AppliedCommits = append(metadata.AppliedCommits, commit.SHA)
+	metadata.MarkApplied(commit.SHA)
 	metadata.LastSyncedAt = time.Now()
 
 	if err := config.SaveProjectMetadata(dir, metadata); err != nil {
@@ -362,36 +615,102 @@ func handleConflictResolution(dir string, metadata *model.ProjectMetadata, syncS
 
 	// Now commit the resolved changes
 	commitMsg := fmt.Sprintf("Synced with %s: %s (resolved conflicts)", metadata.SourceRepo, strings.Split(commit.Message, "\n")[0])
-	if err := git.CommitChanges(dir, commitMsg); err != nil {
+	if err := git.CommitChanges(dir, commitMsg, config.SyncStateFiles()...); err != nil {
 		return fmt.Errorf("failed to commit resolved changes: %w", err)
 	}
 
+	// Sync statuses written before the upstream ref was tracked have no tree
+	if syncStatus.UpstreamTree != "" {
+		if err := recordUpstream(dir, metadata, commit, syncStatus.UpstreamTree); err != nil {
+			return err
+		}
+	}
+
+	// The session goes on with the rest of its queue
+	clearConflict(syncStatus)
+	if err := config.SaveSyncStatus(dir, syncStatus); err != nil {
+		return fmt.Errorf("failed to save sync status: %w", err)
+	}
+
 	fmt.Printf("Successfully applied commit %s with resolved conflicts.\n", commit.SHA[:8])
 	return nil
 }
 
-// CreateProjectFromZip creates a new project from a downloaded zip
-func CreateProjectFromZip(zipData []byte, targetDir, repoFull, branch, commit string) error {
+// CreateProjectFromZip creates a new project from a downloaded zip, substituting
+// the given template variables and prompting for any others the template uses.
+// postCreate says whether to run the manifest's post-create steps; if it is nil
+// the user is asked.
+func CreateProjectFromZip(zipData []byte, targetDir string, server model.ServerConfig, repoFull, refType, ref, commit string, vars map[string]string, postCreate *bool) error {
+	tempDir, rootDir, err := extractTemplate(zipData)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	// Load the template manifest, if the template declares one
+	manifest, err := config.LoadTemplateManifest(rootDir)
+	if err != nil {
+		return err
+	}
+
+	// Excluded files go first, so nothing is asked for the placeholders only they use
+	if err := stripTemplate(rootDir, manifest); err != nil {
+		return err
+	}
+
+	// Fill in template variables
+	if err := collectVariables(rootDir, manifest, vars); err != nil {
+		return err
+	}
+	if err := render.Apply(rootDir, vars); err != nil {
+		return fmt.Errorf("failed to apply template variables: %w", err)
+	}
+
+	// Create target directory
+	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %w", err)
+	}
+
+	// Move content to target directory
+	if err := git.MoveDirContents(rootDir, targetDir); err != nil {
+		return fmt.Errorf("failed to move content: %w", err)
+	}
+
+	// Create metadata
+	if err := config.CreateInitialMetadata(targetDir, server, repoFull, refType, ref, commit, vars); err != nil {
+		return fmt.Errorf("failed to create metadata: %w", err)
+	}
+
+	// Run the template's post-create steps
+	if manifest != nil {
+		if err := runPostCreate(targetDir, manifest.PostCreate, vars, postCreate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractTemplate extracts a template archive into a new temporary directory and
+// returns it along with the archive's root directory. The caller removes tempDir.
+func extractTemplate(zipData []byte) (string, string, error) {
 	// Create temporary directory for extraction
 	tempDir, err := os.MkdirTemp("", "templatamus-")
 	if err != nil {
-		return fmt.Errorf("failed to create temp directory: %w", err)
+		return "", "", fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	defer os.RemoveAll(tempDir)
 
 	// Extract to temporary directory
 	if err := git.ExtractZip(zipData, tempDir); err != nil {
-		return fmt.Errorf("extract failed: %w", err)
+		os.RemoveAll(tempDir)
+		return "", "", fmt.Errorf("extract failed: %w", err)
 	}
 
 	// Find root directory in the extracted content
 	entries, err := os.ReadDir(tempDir)
 	if err != nil {
-		return fmt.Errorf("failed to read temp directory: %w", err)
-	}
-
-	if len(entries) == 0 {
-		return fmt.Errorf("empty zip file")
+		os.RemoveAll(tempDir)
+		return "", "", fmt.Errorf("failed to read temp directory: %w", err)
 	}
 
 	rootDir := ""
@@ -403,23 +722,167 @@ func CreateProjectFromZip(zipData []byte, targetDir, repoFull, branch, commit st
 	}
 
 	if rootDir == "" {
-		return fmt.Errorf("no root directory found in zip")
+		os.RemoveAll(tempDir)
+		if len(entries) == 0 {
+			return "", "", fmt.Errorf("empty zip file")
+		}
+		return "", "", fmt.Errorf("no root directory found in zip")
 	}
 
-	// Create target directory
-	if err := os.MkdirAll(filepath.Dir(targetDir), 0755); err != nil {
-		return fmt.Errorf("failed to create parent directory: %w", err)
+	return tempDir, rootDir, nil
+}
+
+// collectVariables fills in vars from the manifest's declared variables,
+// prompting for values that weren't given. A template with a manifest only gets
+// those, the ones given with -var and ProjectName substituted, so placeholders
+// of its own Go templates, Helm charts or workflows are left alone. Without a
+// manifest every placeholder the template uses is prompted for.
+func collectVariables(dir string, manifest *model.TemplateManifest, vars map[string]string) error {
+	if manifest != nil {
+		for _, v := range manifest.Variables {
+			if value, ok := vars[v.Name]; ok {
+				if err := config.ValidateVariable(v, value); err != nil {
+					return err
+				}
+				continue
+			}
+
+			validate := func(value string) error {
+				return config.ValidateVariable(v, value)
+			}
+			value, err := cli.AskVariable(v, render.String(v.Default, vars), validate)
+			if err != nil {
+				return err
+			}
+			vars[v.Name] = value
+		}
+		return nil
 	}
 
-	// Move content to target directory
-	if err := git.MoveDirContents(rootDir, targetDir); err != nil {
-		return fmt.Errorf("failed to move content: %w", err)
+	names, err := render.FindVariables(dir)
+	if err != nil {
+		return err
 	}
 
-	// Create metadata
-	if err := config.CreateInitialMetadata(targetDir, repoFull, branch, commit); err != nil {
-		return fmt.Errorf("failed to create metadata: %w", err)
+	for _, name := range names {
+		if _, ok := vars[name]; ok {
+			continue
+		}
+		value, err := cli.Input(fmt.Sprintf("Value for template variable %s:", name))
+		if err != nil {
+			return err
+		}
+		vars[name] = value
+	}
+
+	return nil
+}
+
+// stripTemplate removes what doesn't become part of a project from an extracted
+// template: the manifest at its root and the paths the manifest excludes
+func stripTemplate(dir string, manifest *model.TemplateManifest) error {
+	if manifest == nil {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(dir, config.ManifestFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove %s: %w", config.ManifestFile, err)
+	}
+	return removeExcluded(dir, manifest.Exclude)
+}
+
+// removeExcluded deletes files matching the manifest's exclude patterns. Patterns
+// are matched against slash-separated paths relative to dir, and patterns
+// without a slash also match the base name at any depth.
+func removeExcluded(dir string, patterns []string) error {
+	if len(patterns) == 0 {
+		return nil
 	}
 
+	var excluded []string
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || p == dir {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		if matchesAny(filepath.ToSlash(rel), patterns) {
+			excluded = append(excluded, p)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to find excluded files: %w", err)
+	}
+
+	for _, p := range excluded {
+		if err := os.RemoveAll(p); err != nil {
+			return fmt.Errorf("failed to remove excluded path %s: %w", p, err)
+		}
+	}
+
+	return nil
+}
+
+// matchesAny reports whether the slash-separated relative path matches one of the patterns
+func matchesAny(rel string, patterns []string) bool {
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if ok, _ := path.Match(pattern, rel); ok {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runPostCreate runs the manifest's post-create steps in the new project. They
+// are arbitrary shell commands from the template, so they are shown and only
+// run once the user agrees, unless run says whether to run them.
+func runPostCreate(dir string, steps []string, vars map[string]string, run *bool) error {
+	if len(steps) == 0 {
+		return nil
+	}
+	rendered := make([]string, len(steps))
+	for i, step := range steps {
+		rendered[i] = render.String(step, vars)
+	}
+
+	ok := run != nil && *run
+	if run == nil {
+		fmt.Println("The template wants to run these commands in the new project:")
+		for _, step := range rendered {
+			fmt.Printf("  %s\n", step)
+		}
+		var err error
+		ok, err = cli.Confirm("Do you want to run them?", false)
+		if err != nil {
+			return err
+		}
+	}
+	if !ok {
+		fmt.Println("Skipping the template's post-create steps.")
+		return nil
+	}
+
+	for _, step := range rendered {
+		fmt.Printf("Running: %s\n", step)
+
+		cmd := exec.Command("sh", "-c", step)
+		cmd.Dir = dir
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("post-create step %q failed: %w", step, err)
+		}
+	}
 	return nil
-} 
\ No newline at end of file
+}
@@ -0,0 +1,37 @@
+package sync
+
+import (
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"templatamus/internal/model"
+)
+
+func TestCollectVariablesWithManifest(t *testing.T) {
+	dir := t.TempDir()
+	content := "module {{ .Module }}\n\ngo {{ .GoVersion }}\n"
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	manifest := &model.TemplateManifest{Variables: []model.TemplateVariable{
+		{Name: "Module", Type: model.VariableTypeString, Validation: `[a-z./]+`},
+	}}
+
+	// Every declared variable is given, so nothing is prompted for, not even
+	// the placeholder the manifest doesn't declare
+	vars := map[string]string{"ProjectName": "demo", "Module": "example.com/demo"}
+	want := maps.Clone(vars)
+	if err := collectVariables(dir, manifest, vars); err != nil {
+		t.Fatalf("collectVariables: %v", err)
+	}
+	if !maps.Equal(vars, want) {
+		t.Errorf("variables = %v, want %v", vars, want)
+	}
+
+	vars["Module"] = "Example.com/Demo"
+	if err := collectVariables(dir, manifest, vars); err == nil {
+		t.Error("collectVariables accepted a value that doesn't match the validation pattern")
+	}
+}
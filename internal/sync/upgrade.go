@@ -0,0 +1,103 @@
+package sync
+
+import (
+	"fmt"
+
+	"templatamus/internal/cli"
+	"templatamus/internal/config"
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+)
+
+// Upgrade moves the project in dir to the template at another tag in one step:
+// the changes between the template the project was last synced from and the
+// tag are applied as a single three-way merge instead of commit by commit. If
+// tag is empty the user picks one. Conflicts are handled like those of a sync,
+// with resolve, sync --continue and sync --abort, and the metadata records the
+// new tag once the upgrade is committed.
+func Upgrade(dir string, src provider.SourceProvider, tag string) error {
+	metadata, err := config.LoadProjectMetadata(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load project metadata: %w", err)
+	}
+	if err := resolveSource(src, metadata); err != nil {
+		return err
+	}
+
+	syncStatus, err := config.LoadSyncStatus(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load sync status: %w", err)
+	}
+	if syncStatus.InProgress {
+		return fmt.Errorf("a sync is in progress, finish it with 'templatamus sync --continue' or 'templatamus sync --abort' first")
+	}
+
+	hasChanges, err := git.CheckRepoStatus(dir, config.SyncStateFiles()...)
+	if err != nil {
+		return fmt.Errorf("failed to check repository status: %w", err)
+	}
+	if hasChanges {
+		return fmt.Errorf("working directory is not clean, commit or stash your changes first")
+	}
+
+	if tag == "" {
+		tags, err := src.ListTags()
+		if err != nil {
+			return fmt.Errorf("failed to get tags: %w", err)
+		}
+		if len(tags) == 0 {
+			return fmt.Errorf("no tags found in repository")
+		}
+		tag, err = cli.Choose("Choose a tag to upgrade to", tags)
+		if err != nil {
+			return err
+		}
+	}
+
+	target, err := src.ResolveRef(tag)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", tag, err)
+	}
+	base, err := lastSyncedCommit(dir, metadata)
+	if err != nil {
+		return err
+	}
+	if base == target {
+		fmt.Printf("Project is already at %s (%s).\n", tag, shortSHA(target))
+		return nil
+	}
+
+	info, err := src.GetCommit(target)
+	if err != nil {
+		return fmt.Errorf("failed to get commit %s: %w", shortSHA(target), err)
+	}
+	// The upgrade is applied as if the tag were a single commit on top of the base
+	upgrade := *info
+	upgrade.Message = fmt.Sprintf("Upgrade to %s", tag)
+	upgrade.Parents = []string{base}
+
+	fmt.Printf("Upgrading from %s to %s (%s)\n", shortSHA(base), tag, shortSHA(target))
+
+	trees, err := newUpstreamTrees(dir, src, metadata.Variables)
+	if err != nil {
+		return err
+	}
+	if err := startSession(dir, metadata, syncStatus, []model.CommitInfo{upgrade}); err != nil {
+		return err
+	}
+
+	// Saved now, so the new tag is committed with the upgrade even when it
+	// stops at conflicts, and put back by sync --abort
+	metadata.RefType = model.RefTypeTag
+	metadata.Ref = tag
+	metadata.ResolvedSHA = target
+	if err := config.SaveProjectMetadata(dir, metadata); err != nil {
+		if rbErr := rollback(dir, syncStatus); rbErr != nil {
+			return fmt.Errorf("failed to update metadata: %w (rollback failed: %v)", err, rbErr)
+		}
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return runSession(dir, trees, metadata, syncStatus, false)
+}
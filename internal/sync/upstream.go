@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"fmt"
+	"os"
+
+	"templatamus/internal/config"
+	"templatamus/internal/git"
+	"templatamus/internal/model"
+	"templatamus/internal/provider"
+	"templatamus/internal/render"
+)
+
+// TrackUpstream records the template a project was just created from in
+// git.UpstreamRef. The project must already be a git repository.
+func TrackUpstream(dir string, zipData []byte, commit string) error {
+	metadata, err := config.LoadProjectMetadata(dir)
+	if err != nil {
+		return fmt.Errorf("failed to load project metadata: %w", err)
+	}
+
+	tree, _, err := renderTree(dir, zipData, metadata.Variables)
+	if err != nil {
+		return err
+	}
+	return git.RecordUpstream(dir, tree, commit, upstreamMessage(metadata.SourceRepo, commit, "Initial template"))
+}
+
+// upstreamTrees imports the template's trees into the project's repository,
+// rendered the way the project was created, so upstream commits can be merged
+// three-way against their parent. Trees are cached by commit SHA, as each
+// commit's tree is the base of the next one, along with the default ignore
+// patterns of the template's manifest at that commit.
+type upstreamTrees struct {
+	dir     string
+	src     provider.SourceProvider
+	vars    map[string]string
+	trees   map[string]string
+	ignores map[string][]string
+}
+
+// newUpstreamTrees creates the tree cache for a project, seeded with the tree
+// recorded in git.UpstreamRef
+func newUpstreamTrees(dir string, src provider.SourceProvider, vars map[string]string) (*upstreamTrees, error) {
+	u := &upstreamTrees{dir: dir, src: src, vars: vars, trees: make(map[string]string), ignores: make(map[string][]string)}
+
+	sha, tree, err := git.Upstream(dir)
+	if err != nil {
+		return nil, err
+	}
+	if sha != "" && tree != "" {
+		u.trees[sha] = tree
+	}
+	return u, nil
+}
+
+// commitTrees returns the trees before and after an upstream commit
+func (u *upstreamTrees) commitTrees(commit model.CommitInfo) (string, string, error) {
+	var base string
+	var err error
+	if len(commit.Parents) == 0 {
+		base, err = git.EmptyTree(u.dir)
+	} else {
+		base, err = u.tree(commit.Parents[0])
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	target, err := u.tree(commit.SHA)
+	if err != nil {
+		return "", "", err
+	}
+	return base, target, nil
+}
+
+// tree returns the rendered template tree at the given commit
+func (u *upstreamTrees) tree(sha string) (string, error) {
+	if tree, ok := u.trees[sha]; ok {
+		return tree, nil
+	}
+
+	zipData, err := u.src.DownloadArchive(sha)
+	if err != nil {
+		return "", fmt.Errorf("failed to download template at %s: %w", shortSHA(sha), err)
+	}
+
+	tree, manifest, err := renderTree(u.dir, zipData, u.vars)
+	if err != nil {
+		return "", err
+	}
+	u.trees[sha] = tree
+	if manifest != nil {
+		u.ignores[sha] = manifest.SyncIgnore
+	}
+	return tree, nil
+}
+
+// syncIgnore returns the template's default ignore patterns at the given
+// commit. It is nil for commits whose tree came from git.UpstreamRef instead
+// of being rendered.
+func (u *upstreamTrees) syncIgnore(sha string) []string {
+	return u.ignores[sha]
+}
+
+// renderTree renders a template archive the way CreateProjectFromZip does and
+// stores the result as a tree in the project's repository. It also returns the
+// template's manifest, which is nil if it doesn't declare one.
+func renderTree(dir string, zipData []byte, vars map[string]string) (string, *model.TemplateManifest, error) {
+	tempDir, rootDir, err := extractTemplate(zipData)
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(tempDir)
+
+	manifest, err := config.LoadTemplateManifest(rootDir)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := stripTemplate(rootDir, manifest); err != nil {
+		return "", nil, err
+	}
+	if err := render.Apply(rootDir, vars); err != nil {
+		return "", nil, fmt.Errorf("failed to apply template variables: %w", err)
+	}
+
+	tree, err := git.WriteTree(dir, rootDir)
+	if err != nil {
+		return "", nil, err
+	}
+	return tree, manifest, nil
+}
+
+// upstreamMessage is the message of the git.UpstreamRef commit for a template commit
+func upstreamMessage(repo, sha, subject string) string {
+	return fmt.Sprintf("%s@%s: %s", repo, shortSHA(sha), subject)
+}
+
+// shortSHA abbreviates a commit SHA for display
+func shortSHA(sha string) string {
+	if len(sha) > 8 {
+		return sha[:8]
+	}
+	return sha
+}
@@ -0,0 +1,111 @@
+// Package version reads tags as version numbers and orders them
+package version
+
+import (
+	"cmp"
+	"strconv"
+	"strings"
+)
+
+// Version is a tag read as a dotted version number, such as v1.2.3 or 2.0-rc.1
+type Version struct {
+	parts      []int
+	prerelease string
+}
+
+// Parse reads a tag as a version number
+func Parse(tag string) (Version, bool) {
+	s := strings.TrimPrefix(strings.TrimPrefix(tag, "v"), "V")
+	s, prerelease, _ := strings.Cut(s, "-")
+	if s == "" {
+		return Version{}, false
+	}
+
+	v := Version{prerelease: prerelease}
+	for _, field := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(field)
+		if err != nil || n < 0 {
+			return Version{}, false
+		}
+		v.parts = append(v.parts, n)
+	}
+	return v, true
+}
+
+// Compare orders versions by their numbers, missing ones counting as zero. A
+// pre-release comes before the release it leads up to, and pre-releases are
+// ordered the way semantic versioning orders them.
+func (v Version) Compare(other Version) int {
+	for i := 0; i < max(len(v.parts), len(other.parts)); i++ {
+		if c := cmp.Compare(v.part(i), other.part(i)); c != 0 {
+			return c
+		}
+	}
+	switch {
+	case v.prerelease == other.prerelease:
+		return 0
+	case v.prerelease == "":
+		return 1
+	case other.prerelease == "":
+		return -1
+	}
+	return comparePrerelease(v.prerelease, other.prerelease)
+}
+
+// comparePrerelease orders pre-release labels such as rc.2 and rc.10 by their
+// dot-separated identifiers in turn: numbers by value and before words, words
+// alphabetically. A label that is a prefix of the other comes first.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < min(len(as), len(bs)); i++ {
+		x, y := as[i], bs[i]
+		xNum, yNum := isNumeric(x), isNumeric(y)
+		var c int
+		switch {
+		case xNum && yNum:
+			// Compare by length first, so long numbers can't overflow
+			x, y = strings.TrimLeft(x, "0"), strings.TrimLeft(y, "0")
+			c = cmp.Or(cmp.Compare(len(x), len(y)), strings.Compare(x, y))
+		case xNum:
+			c = -1
+		case yNum:
+			c = 1
+		default:
+			c = strings.Compare(x, y)
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return cmp.Compare(len(as), len(bs))
+}
+
+// isNumeric reports whether s is a non-empty string of digits
+func isNumeric(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
+
+// part returns the i-th number of the version, or zero if it has fewer
+func (v Version) part(i int) int {
+	if i < len(v.parts) {
+		return v.parts[i]
+	}
+	return 0
+}
+
+// CompareTags orders tags by version, so v1.10 comes after v1.9. Tags that
+// aren't version numbers come before all versions and are ordered by name, as
+// are versions that are equal, such as v1.0 and 1.0.0.
+func CompareTags(a, b string) int {
+	va, aOK := Parse(a)
+	vb, bOK := Parse(b)
+	switch {
+	case aOK && bOK:
+		return cmp.Or(va.Compare(vb), strings.Compare(a, b))
+	case aOK:
+		return 1
+	case bOK:
+		return -1
+	}
+	return strings.Compare(a, b)
+}
@@ -0,0 +1,64 @@
+package version
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCompare(t *testing.T) {
+	// Each pair is in ascending order
+	tests := []struct {
+		lower, higher string
+	}{
+		{"v1.9", "v1.10"},
+		{"1.2", "1.2.1"},
+		{"v1.9.9", "v2"},
+		{"v1.0.0-rc.2", "v1.0.0-rc.10"},
+		{"v1.0.0-rc.1", "v1.0.0"},
+		{"v1.0.0-alpha", "v1.0.0-alpha.1"},
+		{"v1.0.0-alpha.1", "v1.0.0-alpha.beta"},
+		{"v1.0.0-beta.11", "v1.0.0-rc.1"},
+		{"v1.0.0-1", "v1.0.0-alpha"},
+	}
+
+	for _, tt := range tests {
+		lower, ok := Parse(tt.lower)
+		if !ok {
+			t.Fatalf("Parse(%s) failed", tt.lower)
+		}
+		higher, ok := Parse(tt.higher)
+		if !ok {
+			t.Fatalf("Parse(%s) failed", tt.higher)
+		}
+		if c := lower.Compare(higher); c != -1 {
+			t.Errorf("%s compared with %s = %d, want -1", tt.lower, tt.higher, c)
+		}
+		if c := higher.Compare(lower); c != 1 {
+			t.Errorf("%s compared with %s = %d, want 1", tt.higher, tt.lower, c)
+		}
+	}
+
+	v1, _ := Parse("v1.0")
+	v100, _ := Parse("1.0.0")
+	if c := v1.Compare(v100); c != 0 {
+		t.Errorf("v1.0 compared with 1.0.0 = %d, want 0", c)
+	}
+}
+
+func TestParseRejectsOtherTags(t *testing.T) {
+	for _, tag := range []string{"latest", "v", "release-1.0", "1.x", "v1..2", "-rc.1"} {
+		if v, ok := Parse(tag); ok {
+			t.Errorf("Parse(%s) = %+v, want it rejected", tag, v)
+		}
+	}
+}
+
+func TestCompareTagsSortsVersionsByNumber(t *testing.T) {
+	tags := []string{"v1.9", "latest", "v1.10", "v2.0.0", "v1.10.0-rc.1", "nightly", "v1.2"}
+	slices.SortFunc(tags, CompareTags)
+
+	want := []string{"latest", "nightly", "v1.2", "v1.9", "v1.10.0-rc.1", "v1.10", "v2.0.0"}
+	if !slices.Equal(tags, want) {
+		t.Errorf("sorted tags = %v, want %v", tags, want)
+	}
+}
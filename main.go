@@ -1,7 +0,0 @@
-package main
-
-import "templatamus/cmd/templatamus"
-
-func main() {
-	templatamus.Main()
-}